@@ -0,0 +1,140 @@
+// Command dbtool manages the database schema of the Postgres database
+// named by DATABASE_URL.
+//
+//	dbtool migrate status   list migrations and when they were applied
+//	dbtool migrate up       apply pending migrations
+//	dbtool migrate down     roll back the latest migration
+//	dbtool migrate redo     roll back the latest migration and apply it again
+//	dbtool schema check     compare the tables with the entity structs
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"github.com/xligenda/ods-servers/internal/migrations"
+	"github.com/xligenda/ods-servers/internal/repo"
+	"github.com/xligenda/ods-servers/internal/structs"
+)
+
+const usage = "usage: dbtool migrate status|up|down|redo | dbtool schema check"
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "dbtool:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string) error {
+	if len(args) != 2 || (args[0] != "migrate" && args[0] != "schema") {
+		return errors.New(usage)
+	}
+
+	url := os.Getenv("DATABASE_URL")
+	if url == "" {
+		return errors.New("DATABASE_URL is not set")
+	}
+
+	db, err := sqlx.ConnectContext(ctx, "postgres", url)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	if args[0] == "schema" {
+		if args[1] != "check" {
+			return errors.New(usage)
+		}
+		return checkSchema(ctx, db)
+	}
+
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	return migrate(ctx, migrator, args[1])
+}
+
+func migrate(ctx context.Context, migrator *migrations.Migrator, command string) error {
+	switch command {
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", status.Version, status.Name, applied)
+		}
+		return w.Flush()
+
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			fmt.Printf("applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+		return err
+
+	case "down":
+		migration, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("rolled back %04d_%s\n", migration.Version, migration.Name)
+		return nil
+
+	case "redo":
+		migration, err := migrator.Redo(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("redid %04d_%s\n", migration.Version, migration.Name)
+		return nil
+
+	default:
+		return errors.New(usage)
+	}
+}
+
+func checkSchema(ctx context.Context, db *sqlx.DB) error {
+	issues, err := repo.CheckSchemas(ctx,
+		repo.NewRepository[string, structs.Server](db, "servers"),
+		repo.NewRepository[string, structs.User](db, "users"),
+		repo.NewRepository[string, structs.Invite](db, "invites"),
+		repo.NewRepository[string, structs.InviteSnapshot](db, "invite_snapshots"),
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("%d schema issues", len(issues))
+	}
+
+	fmt.Println("schema matches")
+	return nil
+}
@@ -111,3 +111,26 @@ func (c *RedisCache[T]) Get(key string) (*T, error) {
 func (c *RedisCache[T]) Delete(key string) error {
 	return c.client.Del(context.Background(), fmt.Sprintf("%s:%s", c.collection, key)).Err()
 }
+
+// Clear deletes every entry of the collection.
+func (c *RedisCache[T]) Clear() error {
+	var cursor uint64
+
+	for {
+		keys, nextCursor, err := c.client.Scan(context.Background(), cursor, fmt.Sprintf("%s:*", c.collection), 100).Result()
+		if err != nil {
+			return err
+		}
+
+		if len(keys) > 0 {
+			if err := c.client.Del(context.Background(), keys...).Err(); err != nil {
+				return err
+			}
+		}
+
+		cursor = nextCursor
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package changefeed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Channel is the channel the notify_change trigger of the migration
+// 0006_notify_changes publishes on.
+const Channel = "changes"
+
+const (
+	minReconnectInterval = time.Second
+	maxReconnectInterval = time.Minute
+	// an idle connection is pinged, so a dead one is noticed before TCP does
+	pingInterval = 90 * time.Second
+	// changes waiting for an Invalidate worker, more clear the whole cache
+	invalidationQueueSize = 256
+)
+
+type Operation string
+
+const (
+	Create Operation = "create"
+	Update Operation = "update"
+	// also soft deletes
+	Delete Operation = "delete"
+	// delivered to every subscriber after a reconnect, changes made while
+	// the connection was down are lost and derived state must be rebuilt
+	Resync Operation = "resync"
+)
+
+// Change is the payload of a notification, Table and ID are empty for Resync.
+type Change struct {
+	Table     string    `json:"table"`
+	ID        string    `json:"id"`
+	Operation Operation `json:"operation"`
+}
+
+// Subscriber is called from the goroutine of Run, it must not block.
+type Subscriber func(change Change)
+
+type subscription struct {
+	table string
+	fn    Subscriber
+}
+
+// Listener dispatches the changes published on Channel to its subscribers.
+// The connection is re-established automatically after a loss.
+type Listener struct {
+	listener *pq.Listener
+
+	mu            sync.RWMutex
+	subscriptions map[int]subscription
+	nextID        int
+}
+
+// NewListener creates a listener connecting to the Postgres database named
+// by the connection string, it does not connect before Run.
+func NewListener(dsn string) *Listener {
+	l := &Listener{subscriptions: make(map[int]subscription)}
+	l.listener = pq.NewListener(dsn, minReconnectInterval, maxReconnectInterval, logEvent)
+	return l
+}
+
+func logEvent(event pq.ListenerEventType, err error) {
+	switch event {
+	case pq.ListenerEventConnected:
+		slog.Info("change feed connected")
+	case pq.ListenerEventDisconnected:
+		slog.Warn("change feed disconnected", "error", err)
+	case pq.ListenerEventReconnected:
+		slog.Info("change feed reconnected")
+	case pq.ListenerEventConnectionAttemptFailed:
+		slog.Warn("change feed connection attempt failed", "error", err)
+	}
+}
+
+// Subscribe calls fn for the changes of the table, of all tables when it is
+// empty, until the returned function is called.
+func (l *Listener) Subscribe(table string, fn Subscriber) (unsubscribe func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	id := l.nextID
+	l.nextID++
+	l.subscriptions[id] = subscription{table: table, fn: fn}
+
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.subscriptions, id)
+	}
+}
+
+// Run listens on Channel and dispatches the changes until ctx is done.
+func (l *Listener) Run(ctx context.Context) error {
+	defer l.listener.Close()
+
+	if err := l.listener.Listen(Channel); err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", Channel, err)
+	}
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case notification := <-l.listener.Notify:
+			// pq sends nil after re-establishing the connection
+			if notification == nil {
+				l.dispatch(Change{Operation: Resync})
+				continue
+			}
+
+			var change Change
+			if err := json.Unmarshal([]byte(notification.Extra), &change); err != nil {
+				slog.Warn("invalid change notification", "payload", notification.Extra, "error", err)
+				continue
+			}
+			l.dispatch(change)
+
+		case <-ticker.C:
+			// Ping waits for the server, a dead connection is reported to logEvent
+			go l.listener.Ping()
+		}
+	}
+}
+
+func (l *Listener) dispatch(change Change) {
+	l.mu.RLock()
+	subscribers := make([]Subscriber, 0, len(l.subscriptions))
+	for _, s := range l.subscriptions {
+		if s.table == "" || s.table == change.Table || change.Operation == Resync {
+			subscribers = append(subscribers, s.fn)
+		}
+	}
+	l.mu.RUnlock()
+
+	for _, fn := range subscribers {
+		fn(change)
+	}
+}
+
+// Invalidator drops cached entities, *cache.RedisCache implements it.
+type Invalidator interface {
+	Delete(key string) error
+	Clear() error
+}
+
+// Invalidate removes the cached entity of every change of the table, and
+// clears the cache on Resync. The cache is called from a goroutine of its
+// own, when it falls behind by more than invalidationQueueSize changes the
+// pending ones are replaced by a Clear.
+func (l *Listener) Invalidate(table string, cache Invalidator) (unsubscribe func()) {
+	queue := make(chan Change, invalidationQueueSize)
+	done := make(chan struct{})
+	var overflowed atomic.Bool
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case change := <-queue:
+				// the queue is not empty after an overflow, so it is noticed here
+				if overflowed.Swap(false) {
+					change = Change{Operation: Resync}
+				}
+				invalidate(cache, table, change)
+			}
+		}
+	}()
+
+	unsubscribeFeed := l.Subscribe(table, func(change Change) {
+		select {
+		case queue <- change:
+		default:
+			overflowed.Store(true)
+		}
+	})
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			unsubscribeFeed()
+			close(done)
+		})
+	}
+}
+
+func invalidate(cache Invalidator, table string, change Change) {
+	var err error
+	if change.Operation == Resync {
+		err = cache.Clear()
+	} else {
+		err = cache.Delete(change.ID)
+	}
+
+	if err != nil {
+		slog.Error("failed to invalidate cache", "table", table, "id", change.ID, "error", err)
+	}
+}
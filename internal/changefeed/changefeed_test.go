@@ -0,0 +1,110 @@
+package changefeed
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingCache records its calls and blocks them until released.
+type blockingCache struct {
+	release chan struct{}
+	mu      sync.Mutex
+	calls   []string
+}
+
+func (c *blockingCache) Delete(key string) error {
+	<-c.release
+	c.record("delete " + key)
+	return nil
+}
+
+func (c *blockingCache) Clear() error {
+	<-c.release
+	c.record("clear")
+	return nil
+}
+
+func (c *blockingCache) record(call string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, call)
+}
+
+func (c *blockingCache) recorded() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.calls...)
+}
+
+func newTestListener() *Listener {
+	return &Listener{subscriptions: make(map[int]subscription)}
+}
+
+func waitFor(t *testing.T, cache *blockingCache, n int) []string {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(cache.recorded()) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("calls = %q, want %d", cache.recorded(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return cache.recorded()
+}
+
+func TestInvalidateDoesNotBlockDispatch(t *testing.T) {
+	l := newTestListener()
+	cache := &blockingCache{release: make(chan struct{})}
+	unsubscribe := l.Invalidate("servers", cache)
+	defer unsubscribe()
+
+	var delivered []Change
+	l.Subscribe("", func(change Change) { delivered = append(delivered, change) })
+
+	dispatched := make(chan struct{})
+	go func() {
+		l.dispatch(Change{Table: "servers", ID: "1", Operation: Update})
+		l.dispatch(Change{Table: "users", ID: "2", Operation: Create})
+		l.dispatch(Change{Operation: Resync})
+		close(dispatched)
+	}()
+
+	select {
+	case <-dispatched:
+	case <-time.After(5 * time.Second):
+		t.Fatal("dispatch blocked on the cache")
+	}
+	if len(delivered) != 3 {
+		t.Errorf("delivered = %v", delivered)
+	}
+
+	close(cache.release)
+	calls := waitFor(t, cache, 2)
+	if calls[0] != "delete 1" || calls[1] != "clear" {
+		t.Errorf("calls = %q", calls)
+	}
+}
+
+func TestInvalidateOverflowClears(t *testing.T) {
+	l := newTestListener()
+	cache := &blockingCache{release: make(chan struct{})}
+	unsubscribe := l.Invalidate("servers", cache)
+	defer unsubscribe()
+
+	// the worker holds one change, the queue the next invalidationQueueSize
+	for i := 0; i < invalidationQueueSize+10; i++ {
+		l.dispatch(Change{Table: "servers", ID: "1", Operation: Update})
+	}
+
+	close(cache.release)
+	deadline := time.Now().Add(5 * time.Second)
+	for !slices.Contains(cache.recorded(), "clear") {
+		if time.Now().After(deadline) {
+			t.Fatal("the overflow did not clear the cache")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
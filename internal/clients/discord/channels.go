@@ -5,18 +5,20 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+
+	"github.com/xligenda/ods-servers/internal/structs"
 )
 
 type Channel struct {
-	ID       string  `json:"id"`
-	Type     int     `json:"type"`
-	Name     string  `json:"name"`
-	Position int     `json:"position"`
-	ParentID *string `json:"parent_id"`
-	GuildID  string  `json:"guild_id"`
+	ID       structs.Snowflake  `json:"id"`
+	Type     int                `json:"type"`
+	Name     string             `json:"name"`
+	Position int                `json:"position"`
+	ParentID *structs.Snowflake `json:"parent_id"`
+	GuildID  structs.Snowflake  `json:"guild_id"`
 }
 
-func (c *DiscordClient) FetchGuildChannels(id string) (*[]Channel, error) {
+func (c *DiscordClient) FetchGuildChannels(id structs.Snowflake) (*[]Channel, error) {
 	url := fmt.Sprintf("%s/guilds/%s/channels", API_URL, id)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -7,12 +7,46 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
+
+	"github.com/xligenda/ods-servers/internal/structs"
 )
 
 type InviteCode struct {
-	Code      string `json:"code"`
-	GuildID   string `json:"guild_id"`
-	ChannelID string `json:"channel_id"`
+	Code      string            `json:"code"`
+	GuildID   structs.Snowflake `json:"guild_id"`
+	ChannelID structs.Snowflake `json:"channel_id"`
+	// metadata below is only returned by invite listings and creation
+	Inviter   *User      `json:"inviter,omitempty"`
+	Uses      int        `json:"uses"`
+	MaxUses   int        `json:"max_uses"`
+	MaxAge    int        `json:"max_age"`
+	Temporary bool       `json:"temporary"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	Guild     *Guild     `json:"guild,omitempty"`
+	Channel   *Channel   `json:"channel,omitempty"`
+}
+
+type Guild struct {
+	ID   structs.Snowflake `json:"id"`
+	Name string            `json:"name"`
+}
+
+type User struct {
+	ID         structs.Snowflake `json:"id"`
+	Username   string            `json:"username"`
+	GlobalName *string           `json:"global_name"`
+}
+
+// normalize fills the flat IDs from the nested partial objects Discord returns.
+func (i *InviteCode) normalize() {
+	if i.GuildID == 0 && i.Guild != nil {
+		i.GuildID = i.Guild.ID
+	}
+	if i.ChannelID == 0 && i.Channel != nil {
+		i.ChannelID = i.Channel.ID
+	}
 }
 
 func (c *DiscordClient) FetchInvite(code string) (*InviteCode, error) {
@@ -44,6 +78,7 @@ func (c *DiscordClient) FetchInvite(code string) (*InviteCode, error) {
 	if err := json.Unmarshal(body, &res); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
+	res.normalize()
 
 	return &res, nil
 }
@@ -51,13 +86,15 @@ func (c *DiscordClient) FetchInvite(code string) (*InviteCode, error) {
 // maxAge - time the invite is available, 0 is no limit;
 // maxUsages - amount of uses available, 0 is no limit;
 // temp - temporary member or not;
-func (c *DiscordClient) CreateInvite(channel string, maxAge int, maxUsages int, temp bool) (*InviteCode, error) {
+func (c *DiscordClient) CreateInvite(channel structs.Snowflake, maxAge int, maxUsages int, temp bool) (*InviteCode, error) {
 	url := fmt.Sprintf("%s/channels/%s/invites", "https://discord.com/api/v10", channel)
 
 	bodyPayload := map[string]interface{}{
 		"max_age":   maxAge,
 		"max_uses":  maxUsages,
 		"temporary": temp,
+		// never reuse an existing invite with the same settings
+		"unique": true,
 	}
 
 	bodyBytes, err := json.Marshal(bodyPayload)
@@ -89,6 +126,67 @@ func (c *DiscordClient) CreateInvite(channel string, maxAge int, maxUsages int,
 	if err := json.Unmarshal(body, &res); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
+	res.normalize()
 
 	return &res, nil
 }
+
+func (c *DiscordClient) DeleteInvite(code string) error {
+	url := fmt.Sprintf("%s/invites/%s", API_URL, code)
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+func (c *DiscordClient) ListGuildInvites(guildID structs.Snowflake) ([]InviteCode, error) {
+	return c.listInvites(fmt.Sprintf("%s/guilds/%s/invites", API_URL, guildID))
+}
+
+func (c *DiscordClient) ListChannelInvites(channelID structs.Snowflake) ([]InviteCode, error) {
+	return c.listInvites(fmt.Sprintf("%s/channels/%s/invites", API_URL, channelID))
+}
+
+func (c *DiscordClient) listInvites(url string) ([]InviteCode, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	var res []InviteCode
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	for i := range res {
+		res[i].normalize()
+	}
+
+	return res, nil
+}
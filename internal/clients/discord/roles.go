@@ -5,9 +5,32 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+
+	"github.com/xligenda/ods-servers/internal/structs"
 )
 
-func (c *DiscordClient) FetchGuildRoles(guildID string) (map[string]string, error) {
+type Role struct {
+	ID       structs.Snowflake `json:"id"`
+	Name     string            `json:"name"`
+	Position int               `json:"position"`
+}
+
+// FetchGuildRoles resolves role names to IDs, duplicate names keep the last role listed.
+func (c *DiscordClient) FetchGuildRoles(guildID structs.Snowflake) (map[string]structs.Snowflake, error) {
+	roles, err := c.ListGuildRoles(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	nameToID := make(map[string]structs.Snowflake)
+	for _, role := range roles {
+		nameToID[role.Name] = role.ID
+	}
+
+	return nameToID, nil
+}
+
+func (c *DiscordClient) ListGuildRoles(guildID structs.Snowflake) ([]Role, error) {
 	rolesURL := fmt.Sprintf("%s/guilds/%s/roles", API_URL, guildID)
 	req, err := http.NewRequest("GET", rolesURL, nil)
 	if err != nil {
@@ -29,24 +52,15 @@ func (c *DiscordClient) FetchGuildRoles(guildID string) (map[string]string, erro
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
-	var rolesData []map[string]any
-	if err := json.Unmarshal(body, &rolesData); err != nil {
+	var roles []Role
+	if err := json.Unmarshal(body, &roles); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
-	nameToID := make(map[string]string)
-	for _, role := range rolesData {
-		id, idOk := role["id"].(string)
-		name, nameOk := role["name"].(string)
-		if idOk && nameOk {
-			nameToID[name] = id
-		}
-	}
-
-	return nameToID, nil
+	return roles, nil
 }
 
-func (c *DiscordClient) FetchMemberRoles(guildID, userID string) ([]string, error) {
+func (c *DiscordClient) FetchMemberRoles(guildID, userID structs.Snowflake) ([]structs.Snowflake, error) {
 	url := fmt.Sprintf("%s/guilds/%s/members/%s", API_URL, guildID, userID)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -68,19 +82,12 @@ func (c *DiscordClient) FetchMemberRoles(guildID, userID string) ([]string, erro
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
-	var memberData map[string]any
+	var memberData struct {
+		Roles []structs.Snowflake `json:"roles"`
+	}
 	if err := json.Unmarshal(body, &memberData); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
-	var roleIDs []string
-	if roles, ok := memberData["roles"].([]any); ok {
-		for _, roleID := range roles {
-			if idStr, ok := roleID.(string); ok {
-				roleIDs = append(roleIDs, idStr)
-			}
-		}
-	}
-
-	return roleIDs, nil
+	return memberData.Roles, nil
 }
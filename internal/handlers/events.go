@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/xligenda/ods-servers/internal/changefeed"
+	"github.com/xligenda/ods-servers/internal/middleware"
+	"github.com/xligenda/ods-servers/internal/repo"
+	"github.com/xligenda/ods-servers/internal/structs"
+	"github.com/xligenda/ods-servers/pkg/apierrors"
+)
+
+// heartbeatInterval keeps proxies from closing idle streams and detects
+// clients that went away.
+const heartbeatInterval = 30 * time.Second
+
+type ServerEventsHandler struct {
+	servers repo.Repository[string, structs.Server]
+	feed    *changefeed.Listener
+}
+
+func NewServerEventsHandler(servers repo.Repository[string, structs.Server], feed *changefeed.Listener) *ServerEventsHandler {
+	return &ServerEventsHandler{servers: servers, feed: feed}
+}
+
+// Register mounts the event routes, the router is expected to be behind middleware.Authentication.
+func (h *ServerEventsHandler) Register(router fiber.Router) {
+	router.Get("/servers/:tag/roles/events", middleware.RequireServerRole("tag"), h.Roles)
+}
+
+// Roles streams the role mapping of the server as server-sent events: a
+// "roles" event with the current mapping, another one on every change and
+// a "deleted" event ending the stream when the server is removed.
+func (h *ServerEventsHandler) Roles(c *fiber.Ctx) error {
+	number, err := strconv.Atoi(c.Params("tag"))
+	if err != nil {
+		return apierrors.ErrBadRequest.With("Invalid server tag")
+	}
+	// the id of the notifications, "03" would never match; a fresh string
+	// also outlives the request, unlike the parameter
+	tag := strconv.Itoa(number)
+
+	// subscribed before the read, so no change after it is missed
+	changed := make(chan struct{}, 1)
+	unsubscribe := h.feed.Subscribe("servers", func(change changefeed.Change) {
+		if change.Operation != changefeed.Resync && change.ID != tag {
+			return
+		}
+		// one pending signal is enough, the server is read again anyway
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+
+	server, err := h.servers.FindByID(c.UserContext(), tag)
+	if err != nil {
+		unsubscribe()
+		return err
+	}
+	if server == nil {
+		unsubscribe()
+		return apierrors.ErrNotFound.With("Server not found")
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	// the writer runs after the handler returned and its context was
+	// recycled, the stream ends on the first failed write
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		defer unsubscribe()
+
+		roles := server.Roles
+		if err := writeEvent(w, "roles", server.Version, roles); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+				if err := w.Flush(); err != nil {
+					return
+				}
+
+			case <-changed:
+				current, err := h.servers.FindByID(ctx, tag)
+				if err != nil {
+					slog.Error("failed to reload server", "tag", tag, "error", err)
+					continue
+				}
+				if current == nil {
+					// the stream ends either way
+					_ = writeEvent(w, "deleted", server.Version, nil)
+					return
+				}
+
+				server = current
+				if maps.Equal(current.Roles, roles) {
+					continue
+				}
+				roles = current.Roles
+				if err := writeEvent(w, "roles", current.Version, roles); err != nil {
+					return
+				}
+			}
+		}
+	})
+
+	return nil
+}
+
+func writeEvent(w *bufio.Writer, event string, version int64, data any) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "event: %s\nid: %d\ndata: %s\n\n", event, version, encoded); err != nil {
+		return err
+	}
+	return w.Flush()
+}
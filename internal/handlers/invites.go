@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/xligenda/ods-servers/internal/clients/discord"
+	"github.com/xligenda/ods-servers/internal/middleware"
+	"github.com/xligenda/ods-servers/internal/repo"
+	"github.com/xligenda/ods-servers/internal/structs"
+	"github.com/xligenda/ods-servers/pkg/apierrors"
+)
+
+const (
+	defaultInviteTTL = 24 * time.Hour
+	// the longest max_age Discord accepts for an invite
+	maxInviteTTL = 7 * 24 * time.Hour
+)
+
+type InviteHandler struct {
+	servers repo.Repository[string, structs.Server]
+	invites repo.Repository[string, structs.Invite]
+	discord *discord.DiscordClient
+	// lifetime of issued invites, Discord allows at most 7 days
+	ttl time.Duration
+	// roles allowed to issue and revoke invites on a server
+	issuers []structs.RoleName
+}
+
+func NewInviteHandler(
+	servers repo.Repository[string, structs.Server],
+	invites repo.Repository[string, structs.Invite],
+	client *discord.DiscordClient,
+	ttl time.Duration,
+	issuers ...structs.RoleName,
+) *InviteHandler {
+	if ttl <= 0 {
+		ttl = defaultInviteTTL
+	}
+	ttl = min(ttl, maxInviteTTL)
+
+	return &InviteHandler{
+		servers: servers,
+		invites: invites,
+		discord: client,
+		ttl:     ttl,
+		issuers: issuers,
+	}
+}
+
+// Register mounts the invite routes, the router is expected to be behind middleware.Authentication.
+func (h *InviteHandler) Register(router fiber.Router) {
+	router.Post("/servers/:tag/invites", middleware.RequireServerRole("tag", h.issuers...), h.Issue)
+	router.Get("/invites/:code", h.Get)
+	router.Delete("/invites/:code", h.Revoke)
+}
+
+type issueInviteRequest struct {
+	UserID structs.DiscordID `json:"user_id"`
+}
+
+type inviteResponse struct {
+	*structs.Invite
+	Status string `json:"status"`
+}
+
+func (h *InviteHandler) Issue(c *fiber.Ctx) error {
+	var body issueInviteRequest
+	if err := c.BodyParser(&body); err != nil {
+		return apierrors.ErrBadRequest
+	}
+	if body.UserID == 0 {
+		return apierrors.ErrMissingRequiredField.With("user_id is required")
+	}
+
+	server, err := h.servers.FindByID(c.UserContext(), c.Params("tag"))
+	if err != nil {
+		return err
+	}
+	if server == nil {
+		return apierrors.ErrNotFound.With("Server not found")
+	}
+	if server.InviteChannel == 0 {
+		return apierrors.ErrConflict.With("Server has no invite channel configured")
+	}
+
+	code, err := h.discord.CreateInvite(server.InviteChannel, int(h.ttl.Seconds()), 1, false)
+	if err != nil {
+		return apierrors.ErrBadGateway.With("Failed to create Discord invite")
+	}
+
+	now := time.Now().UTC()
+	invite, err := h.invites.Create(c.UserContext(), structs.Invite{
+		Code:        code.Code,
+		Server:      server.Tag,
+		RequestedBy: middleware.CurrentUser(c).ID,
+		Target:      body.UserID,
+		ExpiresAt:   now.Add(h.ttl),
+		CreatedAt:   now,
+	})
+	if err != nil {
+		// do not leave an untracked invite behind
+		_ = h.discord.DeleteInvite(code.Code)
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(inviteResponse{Invite: invite, Status: inviteStatus(invite)})
+}
+
+// Get returns the tracked invite, limited like Revoke to the requester and
+// the issuers of the server. Uses are recorded by jobs.InviteUsageJob.
+func (h *InviteHandler) Get(c *fiber.Ctx) error {
+	invite, err := h.findAuthorized(c)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(inviteResponse{Invite: invite, Status: inviteStatus(invite)})
+}
+
+func (h *InviteHandler) Revoke(c *fiber.Ctx) error {
+	invite, err := h.findAuthorized(c)
+	if err != nil {
+		return err
+	}
+
+	if inviteStatus(invite) != "pending" {
+		return apierrors.ErrConflict.With("Invite is no longer active")
+	}
+
+	if err := h.discord.DeleteInvite(invite.Code); err != nil {
+		return apierrors.ErrBadGateway.With("Failed to delete Discord invite")
+	}
+
+	now := time.Now().UTC()
+	invite.RevokedAt = &now
+	if invite, err = h.invites.Update(c.UserContext(), invite.Code, *invite); err != nil {
+		return err
+	}
+
+	return c.JSON(inviteResponse{Invite: invite, Status: inviteStatus(invite)})
+}
+
+// findAuthorized loads the invite of the route, visible only to its requester
+// and the issuers of its server.
+func (h *InviteHandler) findAuthorized(c *fiber.Ctx) (*structs.Invite, error) {
+	invite, err := h.invites.FindByID(c.UserContext(), c.Params("code"))
+	if err != nil {
+		return nil, err
+	}
+	if invite == nil {
+		return nil, apierrors.ErrNotFound.With("Invite not found")
+	}
+
+	user := middleware.CurrentUser(c)
+	if user == nil || (user.ID != invite.RequestedBy && !middleware.HasServerRole(user, invite.Server, h.issuers...)) {
+		return nil, apierrors.ErrInsufficientRights
+	}
+
+	return invite, nil
+}
+
+func inviteStatus(invite *structs.Invite) string {
+	switch {
+	case invite.RevokedAt != nil:
+		return "revoked"
+	case invite.UsedAt != nil:
+		return "used"
+	case time.Now().After(invite.ExpiresAt):
+		return "expired"
+	default:
+		return "pending"
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/xligenda/ods-servers/internal/jobs"
+	"github.com/xligenda/ods-servers/internal/middleware"
+	"github.com/xligenda/ods-servers/internal/structs"
+	"github.com/xligenda/ods-servers/pkg/apierrors"
+)
+
+type RoleReportHandler struct {
+	reconciler *jobs.RoleReconcileJob
+	// roles allowed to read the reports of a server, any membership when empty
+	viewers []structs.RoleName
+}
+
+func NewRoleReportHandler(reconciler *jobs.RoleReconcileJob, viewers ...structs.RoleName) *RoleReportHandler {
+	return &RoleReportHandler{reconciler: reconciler, viewers: viewers}
+}
+
+// Register mounts the report routes, the router is expected to be behind middleware.Authentication.
+func (h *RoleReportHandler) Register(router fiber.Router) {
+	router.Get("/servers/roles/report", middleware.RequireAnyServerRole(h.viewers...), h.List)
+	router.Get("/servers/:tag/roles/report", middleware.RequireServerRole("tag", h.viewers...), h.Get)
+}
+
+// List returns the reports of servers with broken role mappings, among
+// those the caller may view.
+func (h *RoleReportHandler) List(c *fiber.Ctx) error {
+	user := middleware.CurrentUser(c)
+
+	broken := []*jobs.RoleReport{}
+	for _, report := range h.reconciler.Reports() {
+		if !middleware.HasServerRole(user, report.Server, h.viewers...) {
+			continue
+		}
+		if len(report.Issues) > 0 || report.Error != "" {
+			broken = append(broken, report)
+		}
+	}
+
+	return c.JSON(broken)
+}
+
+func (h *RoleReportHandler) Get(c *fiber.Ctx) error {
+	tag, err := strconv.Atoi(c.Params("tag"))
+	if err != nil {
+		return apierrors.ErrBadRequest.With("Invalid server tag")
+	}
+
+	report := h.reconciler.Report(tag)
+	if report == nil {
+		return apierrors.ErrNotFound.With("Server was not checked yet")
+	}
+
+	return c.JSON(report)
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/xligenda/ods-servers/internal/middleware"
+	"github.com/xligenda/ods-servers/internal/repo"
+	"github.com/xligenda/ods-servers/internal/structs"
+	"github.com/xligenda/ods-servers/pkg/apierrors"
+)
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 200
+	defaultListLimit    = 100
+	maxListLimit        = 500
+)
+
+// patchableFields are the members of a server merge patch editors may send,
+// "version" being the expected version.
+var patchableFields = map[string]bool{"roles": true, "invite_channel": true, "version": true}
+
+type ServerHandler struct {
+	servers repo.Repository[string, structs.Server]
+	// changes of the servers, the history route is not mounted when nil
+	history repo.AuditTrail
+	// roles allowed to edit a server
+	editors []structs.RoleName
+}
+
+func NewServerHandler(servers repo.Repository[string, structs.Server], history repo.AuditTrail, editors ...structs.RoleName) *ServerHandler {
+	return &ServerHandler{
+		servers: servers,
+		history: history,
+		editors: editors,
+	}
+}
+
+// Register mounts the server routes, the router is expected to be behind middleware.Authentication.
+func (h *ServerHandler) Register(router fiber.Router) {
+	router.Get("/servers", h.List)
+	router.Get("/servers/:tag", h.Get)
+	router.Patch("/servers/:tag", middleware.RequireServerRole("tag", h.editors...), h.Patch)
+	if h.history != nil {
+		router.Get("/servers/:tag/history", middleware.RequireServerRole("tag", h.editors...), h.History)
+	}
+}
+
+// serverSummary is the list view of a server, it leaves out the role mapping.
+type serverSummary struct {
+	Tag           structs.ServerTag `db:"id" json:"tag"`
+	Guild         structs.DiscordID `db:"guild" json:"guild"`
+	InviteChannel structs.DiscordID `db:"invite_channel" json:"invite_channel"`
+}
+
+// List returns the server summaries ordered by ?sort=, e.g. ?sort=-id, and
+// paged by ?limit= (100 by default, at most 500) and ?offset=.
+func (h *ServerHandler) List(c *fiber.Ctx) error {
+	sort, err := repo.ParseSort(c.Query("sort", "id"))
+	if err != nil {
+		return apierrors.ErrBadRequest.With(err.Error())
+	}
+
+	limit := c.QueryInt("limit", defaultListLimit)
+	offset := c.QueryInt("offset", 0)
+	if limit < 1 || limit > maxListLimit || offset < 0 {
+		return apierrors.ErrBadRequest.With("Invalid limit or offset")
+	}
+
+	opts := repo.NewQueryOptions().WithSort(sort...).WithLimit(limit).WithOffset(offset)
+	servers, err := repo.FindInto[serverSummary](c.UserContext(), h.servers, nil, opts)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(servers)
+}
+
+func (h *ServerHandler) Get(c *fiber.Ctx) error {
+	server, err := h.servers.FindByID(c.UserContext(), c.Params("tag"))
+	if err != nil {
+		return err
+	}
+	if server == nil {
+		return apierrors.ErrNotFound.With("Server not found")
+	}
+
+	c.Set(fiber.HeaderETag, etag(server.Version))
+	return c.JSON(server)
+}
+
+// Patch applies a JSON Merge Patch, e.g. {"roles": {"1234": "Администратор", "5678": null}}
+// renames one role mapping and removes another. Only the roles and the
+// invite channel can be changed. The If-Match header or a
+// "version" member makes the patch fail with 409 if the server changed meanwhile.
+func (h *ServerHandler) Patch(c *fiber.Ctx) error {
+	contentType := string(c.Request().Header.ContentType())
+	if !c.Is("json") && !strings.HasPrefix(contentType, "application/merge-patch+json") {
+		return apierrors.ErrUnsupportedMedia
+	}
+
+	patch := c.Body()
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &members); err != nil || members == nil {
+		return apierrors.ErrValidationFailed.With("Merge patch must be a JSON object")
+	}
+	for name := range members {
+		if !patchableFields[name] {
+			return apierrors.ErrValidationFailed.With(fmt.Sprintf("Field %q cannot be patched", name))
+		}
+	}
+
+	if match := c.Get(fiber.HeaderIfMatch); match != "" && match != "*" {
+		version, err := parseETag(match)
+		if err != nil {
+			return apierrors.ErrBadRequest.With("Invalid If-Match header")
+		}
+		if patch, err = withVersion(patch, version); err != nil {
+			return apierrors.ErrValidationFailed.With("Merge patch must be a JSON object")
+		}
+	}
+
+	server, err := h.servers.MergePatch(c.UserContext(), c.Params("tag"), patch)
+	if err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderETag, etag(server.Version))
+	return c.JSON(server)
+}
+
+// History returns the audit records of the server newest first, paged by
+// ?limit= (50 by default, at most 200) and ?offset=.
+func (h *ServerHandler) History(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", defaultHistoryLimit)
+	offset := c.QueryInt("offset", 0)
+	if limit < 1 || limit > maxHistoryLimit || offset < 0 {
+		return apierrors.ErrBadRequest.With("Invalid limit or offset")
+	}
+
+	entries, err := h.history.History(c.UserContext(), c.Params("tag"), limit, offset)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(entries)
+}
+
+func etag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+func parseETag(value string) (int64, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "W/")
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// withVersion sets the expected version of a merge patch.
+func withVersion(patch []byte, version int64) ([]byte, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &members); err != nil || members == nil {
+		return nil, apierrors.ErrValidationFailed
+	}
+
+	members["version"] = json.RawMessage(strconv.FormatInt(version, 10))
+	return json.Marshal(members)
+}
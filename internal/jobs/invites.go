@@ -0,0 +1,205 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/xligenda/ods-servers/internal/clients/discord"
+	"github.com/xligenda/ods-servers/internal/repo"
+	"github.com/xligenda/ods-servers/internal/structs"
+)
+
+const defaultSnapshotInterval = time.Hour
+
+// InviteSnapshotJob periodically records the use count of every invite
+// in the servers' guilds, so joins can be charted per invite code.
+type InviteSnapshotJob struct {
+	servers   repo.Repository[string, structs.Server]
+	snapshots repo.Repository[string, structs.InviteSnapshot]
+	discord   *discord.DiscordClient
+	interval  time.Duration
+}
+
+func NewInviteSnapshotJob(
+	servers repo.Repository[string, structs.Server],
+	snapshots repo.Repository[string, structs.InviteSnapshot],
+	client *discord.DiscordClient,
+	interval time.Duration,
+) *InviteSnapshotJob {
+	if interval <= 0 {
+		interval = defaultSnapshotInterval
+	}
+
+	return &InviteSnapshotJob{
+		servers:   servers,
+		snapshots: snapshots,
+		discord:   client,
+		interval:  interval,
+	}
+}
+
+// Run snapshots immediately and then on every interval until ctx is cancelled.
+func (j *InviteSnapshotJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := j.Snapshot(ctx); err != nil {
+			slog.Error("invite snapshot failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *InviteSnapshotJob) Snapshot(ctx context.Context) error {
+	servers, err := j.servers.Find(ctx, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to load servers: %w", err)
+	}
+
+	takenAt := time.Now().UTC()
+	for _, server := range servers {
+		if server.Guild == 0 {
+			continue
+		}
+
+		invites, err := j.discord.ListGuildInvites(server.Guild)
+		if err != nil {
+			// one unreachable guild should not stop the others
+			slog.Error("failed to list guild invites", "server", server.Tag, "error", err)
+			continue
+		}
+
+		snapshots := make([]structs.InviteSnapshot, 0, len(invites))
+		for _, invite := range invites {
+			snapshot := structs.InviteSnapshot{
+				ID:      fmt.Sprintf("%s:%d", invite.Code, takenAt.Unix()),
+				Code:    invite.Code,
+				Server:  server.Tag,
+				Channel: invite.ChannelID,
+				Uses:    invite.Uses,
+				MaxUses: invite.MaxUses,
+				TakenAt: takenAt,
+			}
+			if invite.Inviter != nil {
+				snapshot.Inviter = invite.Inviter.ID
+			}
+			snapshots = append(snapshots, snapshot)
+		}
+
+		// a rerun within the same second must not fail on the existing rows
+		if _, err := j.snapshots.CreateMany(ctx, snapshots, &repo.BatchOptions{IgnoreConflicts: true}); err != nil {
+			slog.Error("failed to store invite snapshots", "server", server.Tag, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// InviteUsageJob periodically records when the pending invites issued through
+// the API were used, so reading an invite never has to reach Discord.
+type InviteUsageJob struct {
+	servers  repo.Repository[string, structs.Server]
+	invites  repo.Repository[string, structs.Invite]
+	discord  *discord.DiscordClient
+	interval time.Duration
+}
+
+func NewInviteUsageJob(
+	servers repo.Repository[string, structs.Server],
+	invites repo.Repository[string, structs.Invite],
+	client *discord.DiscordClient,
+	interval time.Duration,
+) *InviteUsageJob {
+	if interval <= 0 {
+		interval = defaultSnapshotInterval
+	}
+
+	return &InviteUsageJob{
+		servers:  servers,
+		invites:  invites,
+		discord:  client,
+		interval: interval,
+	}
+}
+
+// Run checks immediately and then on every interval until ctx is cancelled.
+func (j *InviteUsageJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := j.Check(ctx); err != nil {
+			slog.Error("invite usage check failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *InviteUsageJob) Check(ctx context.Context) error {
+	startedAt := time.Now().UTC()
+	pending, err := j.invites.Find(ctx, []repo.Filter{
+		{Field: "used_at", Operator: "IS NULL"},
+		{Field: "revoked_at", Operator: "IS NULL"},
+		{Field: "expires_at", Operator: ">", Value: startedAt},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to load pending invites: %w", err)
+	}
+
+	byServer := make(map[structs.ServerTag][]*structs.Invite)
+	for _, invite := range pending {
+		byServer[invite.Server] = append(byServer[invite.Server], invite)
+	}
+
+	for tag, invites := range byServer {
+		server, err := j.servers.FindByID(ctx, strconv.Itoa(tag))
+		if err != nil {
+			return fmt.Errorf("failed to load server %d: %w", tag, err)
+		}
+		if server == nil || server.Guild == 0 {
+			continue
+		}
+
+		listed, err := j.discord.ListGuildInvites(server.Guild)
+		if err != nil {
+			// one unreachable guild should not stop the others
+			slog.Error("failed to list guild invites", "server", tag, "error", err)
+			continue
+		}
+		listedAt := time.Now().UTC()
+
+		uses := make(map[string]int, len(listed))
+		for _, code := range listed {
+			uses[code.Code] = code.Uses
+		}
+
+		for _, invite := range invites {
+			// single-use invites disappear from Discord once accepted, but also
+			// when they expire, so a missing one only counts while still valid
+			used, listed := uses[invite.Code]
+			if (listed && used == 0) || (!listed && !listedAt.Before(invite.ExpiresAt)) {
+				continue
+			}
+
+			if _, err := j.invites.Patch(ctx, invite.Code, map[string]any{"used_at": listedAt}); err != nil {
+				slog.Error("failed to record invite use", "code", invite.Code, "error", err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,259 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/xligenda/ods-servers/internal/clients/discord"
+	"github.com/xligenda/ods-servers/internal/repo"
+	"github.com/xligenda/ods-servers/internal/structs"
+)
+
+type RoleIssueKind string
+
+const (
+	// the mapped role ID still exists but the guild role has another name
+	RoleRenamed RoleIssueKind = "renamed"
+	// the mapped role ID no longer exists in the guild
+	RoleDeleted RoleIssueKind = "deleted"
+	// several guild roles carry the mapped name, so resolving by name is ambiguous
+	RoleDuplicate RoleIssueKind = "duplicate"
+)
+
+type RoleIssue struct {
+	Kind   RoleIssueKind     `json:"kind"`
+	RoleID structs.DiscordID `json:"role_id"`
+	// name stored in the server mapping
+	Mapped structs.RoleName `json:"mapped"`
+	// current guild role name, empty for deleted roles
+	Current string `json:"current,omitempty"`
+	// other guild roles with the same name, for duplicates
+	Conflicts []structs.DiscordID `json:"conflicts,omitempty"`
+	// whether the mapping was updated to the current name
+	Fixed bool `json:"fixed"`
+}
+
+type RoleReport struct {
+	Server    structs.ServerTag `json:"server"`
+	CheckedAt time.Time         `json:"checked_at"`
+	Issues    []RoleIssue       `json:"issues"`
+	Error     string            `json:"error,omitempty"`
+}
+
+const defaultReconcileInterval = time.Hour
+
+// RoleReconcileJob compares every server role mapping with the guild roles by ID
+// and keeps the latest report per server.
+type RoleReconcileJob struct {
+	servers  repo.Repository[string, structs.Server]
+	discord  *discord.DiscordClient
+	interval time.Duration
+	// rename mappings to follow renamed guild roles instead of only flagging them
+	updateRenamed bool
+
+	mu      sync.RWMutex
+	reports map[structs.ServerTag]*RoleReport
+}
+
+func NewRoleReconcileJob(
+	servers repo.Repository[string, structs.Server],
+	client *discord.DiscordClient,
+	interval time.Duration,
+	updateRenamed bool,
+) *RoleReconcileJob {
+	if interval <= 0 {
+		interval = defaultReconcileInterval
+	}
+
+	return &RoleReconcileJob{
+		servers:       servers,
+		discord:       client,
+		interval:      interval,
+		updateRenamed: updateRenamed,
+		reports:       make(map[structs.ServerTag]*RoleReport),
+	}
+}
+
+// Run reconciles immediately and then on every interval until ctx is cancelled.
+func (j *RoleReconcileJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := j.Reconcile(ctx); err != nil {
+			slog.Error("role reconciliation failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *RoleReconcileJob) Reconcile(ctx context.Context) error {
+	servers, err := j.servers.Find(ctx, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to load servers: %w", err)
+	}
+
+	for _, server := range servers {
+		if server.Guild == 0 {
+			continue
+		}
+
+		report := &RoleReport{Server: server.Tag, CheckedAt: time.Now().UTC()}
+
+		roles, err := j.discord.ListGuildRoles(server.Guild)
+		if err != nil {
+			report.Error = err.Error()
+			j.store(report)
+			continue
+		}
+
+		report.Issues = diffRoles(server.Roles, roles)
+		if j.updateRenamed {
+			if err := j.applyRenames(ctx, server, report.Issues); err != nil {
+				report.Error = err.Error()
+			}
+		}
+
+		j.store(report)
+	}
+
+	return nil
+}
+
+// applyRenames merges the current names of renamed roles into the mapping at
+// the version it was read. When the server was written meanwhile, it retries
+// once with the renames whose mapping is still unchanged.
+func (j *RoleReconcileJob) applyRenames(ctx context.Context, server *structs.Server, issues []RoleIssue) error {
+	renames := structs.ServerRoles{}
+	for _, issue := range issues {
+		if issue.Kind == RoleRenamed {
+			renames[issue.RoleID] = issue.Current
+		}
+	}
+	if len(renames) == 0 {
+		return nil
+	}
+
+	err := j.mergeRenames(ctx, server, renames)
+	if errors.Is(err, repo.ErrVersionConflict) {
+		current, findErr := j.servers.FindByID(ctx, server.GetID())
+		if findErr != nil {
+			return fmt.Errorf("failed to reload server: %w", findErr)
+		}
+		if current == nil {
+			return fmt.Errorf("server %d was deleted", server.Tag)
+		}
+
+		// leave the roles an editor changed in the meantime to them
+		for id := range renames {
+			if current.Roles[id] != server.Roles[id] {
+				delete(renames, id)
+			}
+		}
+		err = j.mergeRenames(ctx, current, renames)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update role mapping: %w", err)
+	}
+
+	for i, issue := range issues {
+		if _, ok := renames[issue.RoleID]; ok && issue.Kind == RoleRenamed {
+			issues[i].Fixed = true
+		}
+	}
+	return nil
+}
+
+func (j *RoleReconcileJob) mergeRenames(ctx context.Context, server *structs.Server, renames structs.ServerRoles) error {
+	if len(renames) == 0 {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]any{"roles": renames, "version": server.Version})
+	if err != nil {
+		return err
+	}
+
+	_, err = j.servers.MergePatch(ctx, server.GetID(), patch)
+	return err
+}
+
+func diffRoles(mapping structs.ServerRoles, roles []discord.Role) []RoleIssue {
+	byID := make(map[structs.DiscordID]discord.Role, len(roles))
+	byName := make(map[string][]structs.DiscordID)
+	for _, role := range roles {
+		byID[role.ID] = role
+		byName[role.Name] = append(byName[role.Name], role.ID)
+	}
+
+	issues := []RoleIssue{}
+	for id, name := range mapping {
+		role, ok := byID[id]
+		switch {
+		case !ok:
+			issues = append(issues, RoleIssue{Kind: RoleDeleted, RoleID: id, Mapped: name})
+			continue
+		case role.Name != name:
+			issues = append(issues, RoleIssue{Kind: RoleRenamed, RoleID: id, Mapped: name, Current: role.Name})
+		}
+
+		if sameName := byName[role.Name]; len(sameName) > 1 {
+			var conflicts []structs.DiscordID
+			for _, other := range sameName {
+				if other != id {
+					conflicts = append(conflicts, other)
+				}
+			}
+			issues = append(issues, RoleIssue{Kind: RoleDuplicate, RoleID: id, Mapped: name, Current: role.Name, Conflicts: conflicts})
+		}
+	}
+
+	sort.Slice(issues, func(a, b int) bool {
+		if issues[a].RoleID != issues[b].RoleID {
+			return issues[a].RoleID < issues[b].RoleID
+		}
+		return issues[a].Kind < issues[b].Kind
+	})
+
+	return issues
+}
+
+func (j *RoleReconcileJob) store(report *RoleReport) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.reports[report.Server] = report
+}
+
+// Report returns the latest report of a server, nil if it was not checked yet.
+func (j *RoleReconcileJob) Report(tag structs.ServerTag) *RoleReport {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.reports[tag]
+}
+
+// Reports returns the latest report of every checked server ordered by tag.
+func (j *RoleReconcileJob) Reports() []*RoleReport {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	reports := make([]*RoleReport, 0, len(j.reports))
+	for _, report := range j.reports {
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(a, b int) bool {
+		return reports[a].Server < reports[b].Server
+	})
+
+	return reports
+}
@@ -0,0 +1,101 @@
+package jobs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/xligenda/ods-servers/internal/clients/discord"
+	"github.com/xligenda/ods-servers/internal/repo"
+	"github.com/xligenda/ods-servers/internal/structs"
+)
+
+func TestDiffRoles(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping structs.ServerRoles
+		roles   []discord.Role
+		want    []RoleIssue
+	}{
+		{
+			name:    "in sync",
+			mapping: structs.ServerRoles{1: "admin", 2: "mod"},
+			roles:   []discord.Role{{ID: 1, Name: "admin"}, {ID: 2, Name: "mod"}},
+			want:    []RoleIssue{},
+		},
+		{
+			name:    "renamed",
+			mapping: structs.ServerRoles{1: "admin"},
+			roles:   []discord.Role{{ID: 1, Name: "owner"}},
+			want:    []RoleIssue{{Kind: RoleRenamed, RoleID: 1, Mapped: "admin", Current: "owner"}},
+		},
+		{
+			name:    "deleted",
+			mapping: structs.ServerRoles{1: "admin", 2: "mod"},
+			roles:   []discord.Role{{ID: 2, Name: "mod"}},
+			want:    []RoleIssue{{Kind: RoleDeleted, RoleID: 1, Mapped: "admin"}},
+		},
+		{
+			name:    "duplicate",
+			mapping: structs.ServerRoles{1: "admin"},
+			roles:   []discord.Role{{ID: 1, Name: "admin"}, {ID: 3, Name: "admin"}, {ID: 4, Name: "admin"}},
+			want:    []RoleIssue{{Kind: RoleDuplicate, RoleID: 1, Mapped: "admin", Current: "admin", Conflicts: []structs.DiscordID{3, 4}}},
+		},
+		{
+			name:    "renamed onto a duplicate",
+			mapping: structs.ServerRoles{1: "admin"},
+			roles:   []discord.Role{{ID: 1, Name: "mod"}, {ID: 2, Name: "mod"}},
+			want: []RoleIssue{
+				{Kind: RoleDuplicate, RoleID: 1, Mapped: "admin", Current: "mod", Conflicts: []structs.DiscordID{2}},
+				{Kind: RoleRenamed, RoleID: 1, Mapped: "admin", Current: "mod"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffRoles(tt.mapping, tt.roles); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffRoles() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyRenamesRetriesOnConflict(t *testing.T) {
+	ctx := context.Background()
+	servers := repo.NewMemoryRepository[string, structs.Server]("servers")
+	job := NewRoleReconcileJob(servers, nil, 0, true)
+
+	read, err := servers.Create(ctx, structs.Server{Tag: 1, Guild: 10, Roles: structs.ServerRoles{1: "admin", 2: "mod", 3: "staff"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an editor remaps role 2 and adds role 4 after the job read the server
+	edited := *read
+	edited.Roles = structs.ServerRoles{1: "admin", 2: "helper", 3: "staff", 4: "support"}
+	if _, err := servers.Update(ctx, "1", edited); err != nil {
+		t.Fatal(err)
+	}
+
+	issues := []RoleIssue{
+		{Kind: RoleRenamed, RoleID: 1, Mapped: "admin", Current: "owner"},
+		{Kind: RoleRenamed, RoleID: 2, Mapped: "mod", Current: "moderator"},
+		{Kind: RoleDeleted, RoleID: 3, Mapped: "staff"},
+	}
+	if err := job.applyRenames(ctx, read, issues); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := servers.FindByID(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := structs.ServerRoles{1: "owner", 2: "helper", 3: "staff", 4: "support"}
+	if !reflect.DeepEqual(stored.Roles, want) {
+		t.Errorf("roles = %v, want %v", stored.Roles, want)
+	}
+	if fixed := []bool{issues[0].Fixed, issues[1].Fixed, issues[2].Fixed}; !reflect.DeepEqual(fixed, []bool{true, false, false}) {
+		t.Errorf("fixed = %v, want [true false false]", fixed)
+	}
+}
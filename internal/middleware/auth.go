@@ -0,0 +1,230 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/xligenda/ods-servers/internal/repo"
+	"github.com/xligenda/ods-servers/internal/structs"
+	"github.com/xligenda/ods-servers/pkg/apierrors"
+)
+
+const userLocalsKey = "user"
+
+type AuthConfig struct {
+	// Next defines a function to skip this middleware when returned true
+	Next func(c *fiber.Ctx) bool
+
+	// APIKeys maps static API keys to the Discord ID of the user they act on behalf of
+	APIKeys map[string]structs.DiscordID
+
+	// APIKeyHeader is the header API keys are read from, "X-API-Key" by default
+	APIKeyHeader string
+
+	// JWTSecret is the HMAC secret used to verify bearer tokens,
+	// JWT authentication is disabled when empty
+	JWTSecret []byte
+
+	// MaxTokenAge rejects tokens issued longer ago, which then need an "iat"
+	// claim; without it a token is valid until its required "exp"
+	MaxTokenAge time.Duration
+
+	// LoadUser resolves the authenticated Discord ID into a user with its server roles
+	LoadUser func(ctx context.Context, id structs.DiscordID) (*structs.User, error)
+}
+
+// Authentication identifies the caller by an API key or a signed JWT
+// (HS256, Discord ID in the "sub" claim, "exp" required) and stores the resolved user
+// in the request locals for the authorization handlers below. The user
+// context names the user as the actor of audited repository writes.
+func Authentication(config AuthConfig) fiber.Handler {
+	cfg := config
+	if cfg.APIKeyHeader == "" {
+		cfg.APIKeyHeader = "X-API-Key"
+	}
+	keys := hashAPIKeys(cfg.APIKeys)
+
+	return func(c *fiber.Ctx) error {
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		id, err := authenticate(c, cfg, keys)
+		if err != nil {
+			return err
+		}
+
+		if cfg.LoadUser == nil {
+			return apierrors.ErrInternal
+		}
+
+		user, err := cfg.LoadUser(c.UserContext(), id)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return apierrors.ErrUnauthorized
+		}
+
+		c.Locals(userLocalsKey, user)
+		c.SetUserContext(repo.WithActor(c.UserContext(), user.ID.String()))
+		return c.Next()
+	}
+}
+
+type apiKey struct {
+	hash [sha256.Size]byte
+	user structs.DiscordID
+}
+
+func hashAPIKeys(keys map[string]structs.DiscordID) []apiKey {
+	hashed := make([]apiKey, 0, len(keys))
+	for key, user := range keys {
+		hashed = append(hashed, apiKey{hash: sha256.Sum256([]byte(key)), user: user})
+	}
+	return hashed
+}
+
+// findAPIKey compares the hashes, equal in length, of every key in constant
+// time, so the response time tells nothing about how much of a key matched.
+func findAPIKey(keys []apiKey, key string) (structs.DiscordID, bool) {
+	hash := sha256.Sum256([]byte(key))
+
+	var user structs.DiscordID
+	found := 0
+	for _, k := range keys {
+		if subtle.ConstantTimeCompare(k.hash[:], hash[:]) == 1 {
+			user = k.user
+			found = 1
+		}
+	}
+
+	return user, found == 1
+}
+
+func authenticate(c *fiber.Ctx, cfg AuthConfig, keys []apiKey) (structs.DiscordID, error) {
+	if key := c.Get(cfg.APIKeyHeader); key != "" {
+		id, ok := findAPIKey(keys, key)
+		if !ok {
+			return 0, apierrors.ErrUnauthorized.With("Invalid API key")
+		}
+		return id, nil
+	}
+
+	header := c.Get(fiber.HeaderAuthorization)
+	token, found := strings.CutPrefix(header, "Bearer ")
+	if !found || token == "" || len(cfg.JWTSecret) == 0 {
+		return 0, apierrors.ErrUnauthorized
+	}
+
+	return parseToken(token, cfg.JWTSecret, cfg.MaxTokenAge)
+}
+
+func parseToken(token string, secret []byte, maxAge time.Duration) (structs.DiscordID, error) {
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) {
+		return secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+		// rejects tokens issued in the future
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return 0, apierrors.ErrExpiredToken
+		}
+		return 0, apierrors.ErrInvalidToken
+	}
+
+	if maxAge > 0 {
+		if claims.IssuedAt == nil {
+			return 0, apierrors.ErrInvalidToken
+		}
+		if time.Since(claims.IssuedAt.Time) > maxAge {
+			return 0, apierrors.ErrExpiredToken
+		}
+	}
+
+	id, err := structs.ParseSnowflake(claims.Subject)
+	if err != nil {
+		return 0, apierrors.ErrInvalidToken
+	}
+
+	return id, nil
+}
+
+// CurrentUser returns the user stored by Authentication, nil for anonymous requests.
+func CurrentUser(c *fiber.Ctx) *structs.User {
+	user, _ := c.Locals(userLocalsKey).(*structs.User)
+	return user
+}
+
+// RequireServerRole allows the request only when the caller holds one of the
+// roles on the server whose tag is taken from the route parameter, e.g.
+//
+//	app.Patch("/servers/:tag", middleware.RequireServerRole("tag", "Главный администратор"), handler)
+func RequireServerRole(param string, roles ...structs.RoleName) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tag, err := strconv.Atoi(c.Params(param))
+		if err != nil {
+			return apierrors.ErrBadRequest.With("Invalid server tag")
+		}
+
+		if !HasServerRole(CurrentUser(c), tag, roles...) {
+			return apierrors.ErrInsufficientRights
+		}
+
+		return c.Next()
+	}
+}
+
+// RequireAnyServerRole allows the request when the caller holds one of the roles on any server.
+func RequireAnyServerRole(roles ...structs.RoleName) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := CurrentUser(c)
+		if user == nil {
+			return apierrors.ErrInsufficientRights
+		}
+
+		for tag := range user.Servers {
+			if HasServerRole(user, tag, roles...) {
+				return c.Next()
+			}
+		}
+
+		return apierrors.ErrInsufficientRights
+	}
+}
+
+// HasServerRole reports whether the user holds any of the roles on the server,
+// an empty roles list only requires a membership.
+func HasServerRole(user *structs.User, tag structs.ServerTag, roles ...structs.RoleName) bool {
+	if user == nil {
+		return false
+	}
+
+	held, ok := user.Servers[tag]
+	if !ok {
+		return false
+	}
+	if len(roles) == 0 {
+		return true
+	}
+
+	for _, role := range roles {
+		if slices.Contains(held, role) {
+			return true
+		}
+	}
+
+	return false
+}
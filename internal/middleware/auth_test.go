@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/xligenda/ods-servers/internal/structs"
+	"github.com/xligenda/ods-servers/pkg/apierrors"
+)
+
+func TestParseToken(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Now()
+	sign := func(claims jwt.RegisteredClaims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	at := func(t time.Time) *jwt.NumericDate { return jwt.NewNumericDate(t) }
+
+	tests := []struct {
+		name   string
+		token  string
+		maxAge time.Duration
+		want   error
+	}{
+		{"valid", sign(jwt.RegisteredClaims{Subject: "42", ExpiresAt: at(now.Add(time.Hour))}), 0, nil},
+		{"without exp", sign(jwt.RegisteredClaims{Subject: "42"}), 0, apierrors.ErrInvalidToken},
+		{"expired", sign(jwt.RegisteredClaims{Subject: "42", ExpiresAt: at(now.Add(-time.Hour))}), 0, apierrors.ErrExpiredToken},
+		{"issued in the future", sign(jwt.RegisteredClaims{Subject: "42", ExpiresAt: at(now.Add(2 * time.Hour)), IssuedAt: at(now.Add(time.Hour))}), 0, apierrors.ErrInvalidToken},
+		{"within max age", sign(jwt.RegisteredClaims{Subject: "42", ExpiresAt: at(now.Add(time.Hour)), IssuedAt: at(now.Add(-time.Minute))}), time.Hour, nil},
+		{"older than max age", sign(jwt.RegisteredClaims{Subject: "42", ExpiresAt: at(now.Add(time.Hour)), IssuedAt: at(now.Add(-2 * time.Hour))}), time.Hour, apierrors.ErrExpiredToken},
+		{"max age without iat", sign(jwt.RegisteredClaims{Subject: "42", ExpiresAt: at(now.Add(time.Hour))}), time.Hour, apierrors.ErrInvalidToken},
+		{"invalid subject", sign(jwt.RegisteredClaims{Subject: "me", ExpiresAt: at(now.Add(time.Hour))}), 0, apierrors.ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := parseToken(tt.token, secret, tt.maxAge)
+			if err != tt.want {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if err == nil && id != 42 {
+				t.Errorf("id = %d, want 42", id)
+			}
+		})
+	}
+}
+
+func TestFindAPIKey(t *testing.T) {
+	keys := hashAPIKeys(map[string]structs.DiscordID{"first": 1, "second": 2})
+
+	tests := map[string]structs.DiscordID{"first": 1, "second": 2, "secon": 0, "second2": 0, "": 0}
+	for key, want := range tests {
+		id, ok := findAPIKey(keys, key)
+		if ok != (want != 0) || id != want {
+			t.Errorf("findAPIKey(%q) = %d, %v, want %d", key, id, ok, want)
+		}
+	}
+}
@@ -1,12 +1,16 @@
 package middleware
 
 import (
+	"errors"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/xligenda/ods-servers/internal/repo"
 	"github.com/xligenda/ods-servers/pkg/apierrors"
 )
 
 func ErrorHandler(ctx *fiber.Ctx, err error) error {
-	if apiErr, ok := err.(*apierrors.APIError); ok {
+	var apiErr *apierrors.APIError
+	if errors.As(repo.ToAPIError(err), &apiErr) {
 		return ctx.Status(apiErr.StatusCode).JSON(apiErr)
 	}
 	return ctx.Status(apierrors.ErrInternal.StatusCode).JSON(apierrors.ErrInternal.Message)
@@ -0,0 +1,307 @@
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// lockKey identifies the advisory lock serialising migrations across replicas.
+const lockKey int64 = 0x6f64735f6d6967 // "ods_mig"
+
+var ErrNoMigration = errors.New("no migration to roll back")
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a schema change, files are named NNNN_name.up.sql and
+// NNNN_name.down.sql.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Migration
+	// nil while pending
+	AppliedAt *time.Time
+}
+
+// Migrator applies the embedded migrations to a Postgres database and
+// records them in the schema_migrations table.
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *sqlx.DB) (*Migrator, error) {
+	migrations, err := Load(files)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// AutoMigrate applies all pending migrations, to be called on startup.
+func AutoMigrate(ctx context.Context, db *sqlx.DB) error {
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	applied, err := migrator.Up(ctx)
+	for _, migration := range applied {
+		slog.Info("applied migration", "version", migration.Version, "name", migration.Name)
+	}
+	return err
+}
+
+// Load reads the migrations of fsys ordered by version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	paths, err := fs.Glob(fsys, "sql/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, p := range paths {
+		match := fileName.FindStringSubmatch(path.Base(p))
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", p)
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", p, err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names, %s and %s", version, migration.Name, match[2])
+		}
+
+		content, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return nil, err
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Status lists every known migration with the time it was applied. It only
+// reads, without waiting for the migration lock, so a migration running
+// concurrently may or may not be listed as applied. Before the first
+// migration nothing is applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var exists bool
+	if err := m.db.GetContext(ctx, &exists, "SELECT to_regclass('schema_migrations') IS NOT NULL"); err != nil {
+		return nil, fmt.Errorf("failed to look up schema_migrations: %w", err)
+	}
+
+	applied := map[int64]time.Time{}
+	if exists {
+		var err error
+		if applied, err = appliedVersions(ctx, m.db); err != nil {
+			return nil, err
+		}
+	}
+
+	return statusOf(m.migrations, applied), nil
+}
+
+// statusOf pairs the migrations with the times of the applied versions.
+func statusOf(migrations []Migration, applied map[int64]time.Time) []Status {
+	statuses := make([]Status, len(migrations))
+	for i, migration := range migrations {
+		statuses[i] = Status{Migration: migration}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+	return statuses
+}
+
+// Up applies the pending migrations in order, each in its own transaction.
+// It returns the applied migrations, also when a later one failed.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sqlx.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			err := inTx(ctx, conn, migration.Up,
+				"INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", migration.Version, migration.Name)
+			if err != nil {
+				return fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// Down rolls back the latest applied migration.
+func (m *Migrator) Down(ctx context.Context) (*Migration, error) {
+	var rolledBack *Migration
+	err := m.withLock(ctx, func(conn *sqlx.Conn) error {
+		var err error
+		rolledBack, err = m.down(ctx, conn)
+		return err
+	})
+
+	return rolledBack, err
+}
+
+// Redo rolls back the latest applied migration and applies it again.
+func (m *Migrator) Redo(ctx context.Context) (*Migration, error) {
+	var redone *Migration
+	err := m.withLock(ctx, func(conn *sqlx.Conn) error {
+		migration, err := m.down(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		err = inTx(ctx, conn, migration.Up,
+			"INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", migration.Version, migration.Name)
+		if err != nil {
+			return fmt.Errorf("failed to reapply migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		redone = migration
+		return nil
+	})
+
+	return redone, err
+}
+
+func (m *Migrator) down(ctx context.Context, conn *sqlx.Conn) (*Migration, error) {
+	var version int64
+	err := conn.GetContext(ctx, &version, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	if version == 0 {
+		return nil, ErrNoMigration
+	}
+
+	i := sort.Search(len(m.migrations), func(i int) bool { return m.migrations[i].Version >= version })
+	if i == len(m.migrations) || m.migrations[i].Version != version {
+		return nil, fmt.Errorf("applied migration %d is unknown to this build", version)
+	}
+	migration := m.migrations[i]
+	if migration.Down == "" {
+		return nil, fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+	}
+
+	err = inTx(ctx, conn, migration.Down, "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to roll back migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	return &migration, nil
+}
+
+// withLock runs fn on a single connection holding the advisory lock, so
+// concurrently starting replicas migrate one after another.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sqlx.Conn) error) error {
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	// the lock belongs to the session, release it even when ctx is done
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey)
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    BIGINT PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func appliedVersions(ctx context.Context, q sqlx.QueryerContext) (map[int64]time.Time, error) {
+	var rows []struct {
+		Version   int64     `db:"version"`
+		AppliedAt time.Time `db:"applied_at"`
+	}
+	if err := sqlx.SelectContext(ctx, q, &rows, "SELECT version, applied_at FROM schema_migrations"); err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+
+	applied := make(map[int64]time.Time, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row.AppliedAt
+	}
+	return applied, nil
+}
+
+// inTx runs the migration script and its bookkeeping statement atomically.
+func inTx(ctx context.Context, conn *sqlx.Conn, script, bookkeeping string, args ...any) error {
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
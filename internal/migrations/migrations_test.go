@@ -0,0 +1,143 @@
+package migrations
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/xligenda/ods-servers/internal/repo/sqlite"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/0010_add_index.up.sql":  {Data: []byte("CREATE INDEX i ON t (a);")},
+		"sql/0002_create_t.up.sql":   {Data: []byte("CREATE TABLE t (a INT);")},
+		"sql/0002_create_t.down.sql": {Data: []byte("DROP TABLE t;")},
+		"sql/0001_create_s.down.sql": {Data: []byte("DROP TABLE s;")},
+		"sql/0001_create_s.up.sql":   {Data: []byte("CREATE TABLE s (a INT);")},
+		"sql/0003_seed.up.sql":       {Data: []byte("INSERT INTO t VALUES (1);")},
+		"other/0004_ignored.up.sql":  {Data: []byte("SELECT 1;")},
+		"sql/README.md":              {Data: []byte("ignored, not a .sql file")},
+	}
+
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Migration{
+		{Version: 1, Name: "create_s", Up: "CREATE TABLE s (a INT);", Down: "DROP TABLE s;"},
+		{Version: 2, Name: "create_t", Up: "CREATE TABLE t (a INT);", Down: "DROP TABLE t;"},
+		{Version: 3, Name: "seed", Up: "INSERT INTO t VALUES (1);"},
+		{Version: 10, Name: "add_index", Up: "CREATE INDEX i ON t (a);"},
+	}
+	if len(migrations) != len(want) {
+		t.Fatalf("loaded %d migrations, want %d", len(migrations), len(want))
+	}
+	for i := range want {
+		if migrations[i] != want[i] {
+			t.Errorf("migration %d = %+v, want %+v", i, migrations[i], want[i])
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		want  string
+	}{
+		{
+			name: "duplicate version",
+			files: fstest.MapFS{
+				"sql/0001_create_s.up.sql": {Data: []byte("CREATE TABLE s (a INT);")},
+				"sql/0001_create_t.up.sql": {Data: []byte("CREATE TABLE t (a INT);")},
+			},
+			want: "has two names",
+		},
+		{
+			name: "same version with other digits",
+			files: fstest.MapFS{
+				"sql/0001_create_s.up.sql": {Data: []byte("CREATE TABLE s (a INT);")},
+				"sql/1_create_t.up.sql":    {Data: []byte("CREATE TABLE t (a INT);")},
+			},
+			want: "has two names",
+		},
+		{
+			name: "down without up",
+			files: fstest.MapFS{
+				"sql/0001_create_s.down.sql": {Data: []byte("DROP TABLE s;")},
+			},
+			want: "has no up file",
+		},
+		{
+			name: "invalid file name",
+			files: fstest.MapFS{
+				"sql/create_s.up.sql": {Data: []byte("CREATE TABLE s (a INT);")},
+			},
+			want: "invalid migration file name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Load(tt.files); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := Load(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, migration := range migrations {
+		if migration.Version != int64(i+1) {
+			t.Errorf("migration %d_%s, want version %d", migration.Version, migration.Name, i+1)
+		}
+		if migration.Down == "" {
+			t.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+		}
+	}
+}
+
+func TestStatus(t *testing.T) {
+	db, err := sqlite.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	db.MustExec(`CREATE TABLE schema_migrations (
+		version    BIGINT PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at DATETIME NOT NULL
+	)`)
+	appliedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	db.MustExec("INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)", 1, "create_s", appliedAt)
+	// applied by a newer build, not known to this one
+	db.MustExec("INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)", 9, "future", appliedAt)
+
+	applied, err := appliedVersions(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 2 || !applied[1].Equal(appliedAt) {
+		t.Fatalf("applied = %v, want versions 1 and 9 at %v", applied, appliedAt)
+	}
+
+	statuses := statusOf([]Migration{{Version: 1, Name: "create_s"}, {Version: 2, Name: "create_t"}}, applied)
+	if len(statuses) != 2 {
+		t.Fatalf("got %d statuses, want 2", len(statuses))
+	}
+	if statuses[0].AppliedAt == nil || !statuses[0].AppliedAt.Equal(appliedAt) {
+		t.Errorf("migration 1 applied at %v, want %v", statuses[0].AppliedAt, appliedAt)
+	}
+	if statuses[1].AppliedAt != nil {
+		t.Errorf("migration 2 applied at %v, want pending", statuses[1].AppliedAt)
+	}
+}
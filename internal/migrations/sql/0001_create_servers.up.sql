@@ -0,0 +1,11 @@
+CREATE TABLE servers (
+    id             INTEGER PRIMARY KEY,
+    guild          BIGINT NOT NULL,
+    invite_channel BIGINT NOT NULL DEFAULT 0,
+    roles          JSONB NOT NULL DEFAULT '{}',
+    version        BIGINT NOT NULL DEFAULT 0,
+    deleted_at     TIMESTAMPTZ
+);
+
+-- a guild belongs to one active server, deleted ones keep their guild
+CREATE UNIQUE INDEX servers_guild_key ON servers (guild) WHERE deleted_at IS NULL;
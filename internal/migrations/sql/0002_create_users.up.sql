@@ -0,0 +1,7 @@
+CREATE TABLE users (
+    id      BIGINT PRIMARY KEY,
+    servers JSONB NOT NULL DEFAULT '{}'
+);
+
+-- serves the containment and key filters on the role memberships
+CREATE INDEX users_servers_idx ON users USING GIN (servers);
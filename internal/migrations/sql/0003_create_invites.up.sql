@@ -0,0 +1,13 @@
+CREATE TABLE invites (
+    id           TEXT PRIMARY KEY,
+    server       INTEGER NOT NULL REFERENCES servers (id),
+    requested_by BIGINT NOT NULL,
+    target       BIGINT NOT NULL,
+    expires_at   TIMESTAMPTZ NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    used_at      TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ
+);
+
+CREATE INDEX invites_server_idx ON invites (server);
+CREATE INDEX invites_target_idx ON invites (target);
@@ -0,0 +1,12 @@
+CREATE TABLE invite_snapshots (
+    id       TEXT PRIMARY KEY,
+    code     TEXT NOT NULL,
+    server   INTEGER NOT NULL REFERENCES servers (id),
+    channel  BIGINT NOT NULL,
+    inviter  BIGINT NOT NULL,
+    uses     INTEGER NOT NULL,
+    max_uses INTEGER NOT NULL,
+    taken_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX invite_snapshots_code_idx ON invite_snapshots (code, taken_at);
@@ -0,0 +1,12 @@
+CREATE TABLE audit_log (
+    id         BIGSERIAL PRIMARY KEY,
+    table_name TEXT NOT NULL,
+    entity_id  TEXT NOT NULL,
+    operation  TEXT NOT NULL CHECK (operation IN ('create', 'update', 'delete')),
+    -- NULL for writes made outside of a request
+    actor      TEXT,
+    changes    JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX audit_log_entity_idx ON audit_log (table_name, entity_id, id);
@@ -0,0 +1,5 @@
+DROP TRIGGER invite_snapshots_notify_change ON invite_snapshots;
+DROP TRIGGER invites_notify_change ON invites;
+DROP TRIGGER users_notify_change ON users;
+DROP TRIGGER servers_notify_change ON servers;
+DROP FUNCTION notify_change();
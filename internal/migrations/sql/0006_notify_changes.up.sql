@@ -0,0 +1,29 @@
+-- publishes the row changes of the entity tables on the "changes" channel,
+-- listeners receive them once the writing transaction commits
+CREATE FUNCTION notify_change() RETURNS trigger AS $$
+DECLARE
+    entity    JSONB := to_jsonb(COALESCE(NEW, OLD));
+    operation TEXT  := CASE TG_OP WHEN 'INSERT' THEN 'create' WHEN 'UPDATE' THEN 'update' ELSE 'delete' END;
+BEGIN
+    -- a soft delete is an update setting deleted_at
+    IF TG_OP = 'UPDATE' AND to_jsonb(OLD) ->> 'deleted_at' IS NULL AND entity ->> 'deleted_at' IS NOT NULL THEN
+        operation := 'delete';
+    END IF;
+
+    PERFORM pg_notify('changes', json_build_object(
+        'table', TG_TABLE_NAME,
+        'id', entity ->> 'id',
+        'operation', operation
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER servers_notify_change AFTER INSERT OR UPDATE OR DELETE ON servers
+    FOR EACH ROW EXECUTE FUNCTION notify_change();
+CREATE TRIGGER users_notify_change AFTER INSERT OR UPDATE OR DELETE ON users
+    FOR EACH ROW EXECUTE FUNCTION notify_change();
+CREATE TRIGGER invites_notify_change AFTER INSERT OR UPDATE OR DELETE ON invites
+    FOR EACH ROW EXECUTE FUNCTION notify_change();
+CREATE TRIGGER invite_snapshots_notify_change AFTER INSERT OR UPDATE OR DELETE ON invite_snapshots
+    FOR EACH ROW EXECUTE FUNCTION notify_change();
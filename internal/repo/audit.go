@@ -0,0 +1,370 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// auditTable is created by the migration 0005_create_audit_log.
+const auditTable = "audit_log"
+
+type AuditOperation string
+
+const (
+	AuditCreate AuditOperation = "create"
+	AuditUpdate AuditOperation = "update"
+	AuditDelete AuditOperation = "delete"
+)
+
+// FieldChange is the JSON encoding of a column before and after a write,
+// Before is absent for created rows and After for hard-deleted ones.
+type FieldChange struct {
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// AuditChanges maps the columns a write changed to their values, stored as JSON.
+type AuditChanges map[string]FieldChange
+
+func (c *AuditChanges) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, c)
+	case string:
+		return json.Unmarshal([]byte(v), c)
+	default:
+		return fmt.Errorf("cannot scan %T into AuditChanges", src)
+	}
+}
+
+// Value is the JSON text, a []byte would be sent to Postgres as bytea.
+func (c AuditChanges) Value() (driver.Value, error) {
+	encoded, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(encoded), nil
+}
+
+// AuditEntry is a row of the audit log.
+type AuditEntry struct {
+	ID        int64          `db:"id" json:"id"`
+	Table     string         `db:"table_name" json:"table"`
+	EntityID  string         `db:"entity_id" json:"entity_id"`
+	Operation AuditOperation `db:"operation" json:"operation"`
+	// nil for writes without an actor in the context, e.g. by jobs
+	Actor     *string      `db:"actor" json:"actor"`
+	Changes   AuditChanges `db:"changes" json:"changes"`
+	CreatedAt time.Time    `db:"created_at" json:"created_at"`
+}
+
+// AuditTrail is implemented by repositories recording their writes.
+type AuditTrail interface {
+	// History lists the audit records of the entity, newest first.
+	History(ctx context.Context, id string, limit, offset int) ([]AuditEntry, error)
+}
+
+type actorKey struct{}
+
+// WithActor attributes the writes made with the context to the actor, e.g.
+// the Discord ID of the authenticated user.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func ActorFromContext(ctx context.Context) (string, bool) {
+	actor, ok := ctx.Value(actorKey{}).(string)
+	return actor, ok
+}
+
+// WithAudit records the changes made by Create, CreateMany, Update, Upsert,
+// UpsertMany, Patch (and so MergePatch), Delete, DeleteMany, Restore and
+// Purge in the audit_log table, within the transaction of the write.
+// Postgres gets the table from the migrations, SQLite from
+// sqlite.AuditLogSchema. MemoryRepository ignores it.
+func WithAudit() Option {
+	return func(o *options) {
+		o.audit = true
+	}
+}
+
+// auditScope selects the rows a write may change.
+type auditScope struct {
+	filters []Filter
+	deleted DeletedScope
+	// with columns, only rows whose columns equal one of the keys
+	columns []string
+	keys    [][]any
+}
+
+// keyScope selects the rows the entities conflict with on the columns,
+// deleted or not.
+func (r *GenericRepository[I, T]) keyScope(entities []T, columns []string) *auditScope {
+	scope := &auditScope{deleted: IncludeDeleted, columns: columns, keys: make([][]any, len(entities))}
+	for i, entity := range entities {
+		v := reflect.ValueOf(entity)
+		scope.keys[i] = make([]any, len(columns))
+		for j, column := range columns {
+			scope.keys[i][j] = r.getFieldValue(v.Field(r.columns[column]))
+		}
+	}
+	return scope
+}
+
+func idScope(id string) *auditScope {
+	return &auditScope{filters: []Filter{{Field: "id", Operator: "=", Value: id}}}
+}
+
+// audited runs write and, when auditing, records the rows it changed in the
+// same transaction. The rows of the scope are locked and read before the
+// write, a nil scope selects none. write returns the rows as they are
+// afterwards, rows of the scope it does not return count as deleted.
+func (r *GenericRepository[I, T]) audited(ctx context.Context, operation string, scope *auditScope, write func(ctx context.Context) ([]*T, error)) error {
+	if !r.options.audit {
+		_, err := write(ctx)
+		return err
+	}
+
+	return r.Transaction(ctx, nil, func(ctx context.Context) error {
+		var before []*T
+		if scope != nil {
+			var err error
+			if before, err = r.lockRows(ctx, operation, scope); err != nil {
+				return err
+			}
+		}
+
+		after, err := write(ctx)
+		if err != nil {
+			return err
+		}
+
+		return r.recordChanges(ctx, operation, before, after)
+	})
+}
+
+// auditedOne is audited for writes of a single entity.
+func (r *GenericRepository[I, T]) auditedOne(ctx context.Context, operation string, scope *auditScope, write func(ctx context.Context) (*T, error)) (*T, error) {
+	var written *T
+	err := r.audited(ctx, operation, scope, func(ctx context.Context) ([]*T, error) {
+		var err error
+		written, err = write(ctx)
+		return []*T{written}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return written, nil
+}
+
+// lockRows reads the rows of the scope and locks them until the transaction
+// ends. Keys are matched in chunks staying under the parameter limit.
+func (r *GenericRepository[I, T]) lockRows(ctx context.Context, operation string, scope *auditScope) ([]*T, error) {
+	if len(scope.columns) == 0 {
+		return r.lockMatching(ctx, operation, scope.filters, scope.deleted)
+	}
+
+	var result []*T
+	chunkSize := max(1, r.dialect.maxParams()/len(scope.columns))
+	for start := 0; start < len(scope.keys); start += chunkSize {
+		chunk := scope.keys[start:min(start+chunkSize, len(scope.keys))]
+
+		alternatives := make([]Expr, len(chunk))
+		for i, key := range chunk {
+			conditions := make([]Expr, len(scope.columns))
+			for j, column := range scope.columns {
+				conditions[j] = Eq(column, key[j])
+			}
+			alternatives[i] = And(conditions...)
+		}
+
+		filters := append(scope.filters[:len(scope.filters):len(scope.filters)], NewExprFilter(Or(alternatives...)))
+		rows, err := r.lockMatching(ctx, operation, filters, scope.deleted)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, rows...)
+	}
+
+	return result, nil
+}
+
+func (r *GenericRepository[I, T]) lockMatching(ctx context.Context, operation string, filters []Filter, deleted DeletedScope) ([]*T, error) {
+	query, args, err := r.buildSelectQuery(filters, &QueryOptions{Deleted: deleted})
+	if err != nil {
+		return nil, err
+	}
+
+	var entities []T
+	err = r.conn(ctx, operation).SelectContext(ctx, &entities, query+r.dialect.forUpdate(), args...)
+	if err != nil {
+		return nil, wrapError("failed to lock entities", err)
+	}
+
+	result := make([]*T, len(entities))
+	for i := range entities {
+		result[i] = &entities[i]
+	}
+	return result, nil
+}
+
+// recordChanges writes an audit record for every row whose columns changed.
+func (r *GenericRepository[I, T]) recordChanges(ctx context.Context, operation string, before, after []*T) error {
+	previous := make(map[string]*T, len(before))
+	for _, entity := range before {
+		previous[fmt.Sprint((*entity).GetID())] = entity
+	}
+
+	var entries []AuditEntry
+	record := func(id string, op AuditOperation, old, current *T) error {
+		changes, err := r.diff(old, current)
+		if err != nil {
+			return fmt.Errorf("failed to encode changes of entity with id %s: %w", id, err)
+		}
+		if len(changes) > 0 {
+			entries = append(entries, AuditEntry{Table: r.tableName, EntityID: id, Operation: op, Changes: changes})
+		}
+		return nil
+	}
+
+	for _, entity := range after {
+		id := fmt.Sprint((*entity).GetID())
+		op := AuditCreate
+		old, ok := previous[id]
+		if ok {
+			op = AuditUpdate
+			delete(previous, id)
+		}
+		if err := record(id, op, old, entity); err != nil {
+			return err
+		}
+	}
+
+	var deleted []string
+	for _, entity := range before {
+		if id := fmt.Sprint((*entity).GetID()); previous[id] != nil {
+			deleted = append(deleted, id)
+		}
+	}
+
+	// soft-deleted rows are read again for their deleted_at
+	remaining := make(map[string]*T)
+	if r.softDelete && len(deleted) > 0 {
+		rows, err := r.lockRows(ctx, operation, &auditScope{
+			filters: []Filter{{Field: "id", Operator: "IN", Value: deleted}},
+			deleted: IncludeDeleted,
+		})
+		if err != nil {
+			return err
+		}
+		for _, entity := range rows {
+			remaining[fmt.Sprint((*entity).GetID())] = entity
+		}
+	}
+	for _, id := range deleted {
+		if err := record(id, AuditDelete, previous[id], remaining[id]); err != nil {
+			return err
+		}
+	}
+
+	return r.insertAudit(ctx, operation, entries)
+}
+
+// diff lists the columns whose JSON encoding differs, a nil entity has no columns.
+func (r *GenericRepository[I, T]) diff(before, after *T) (AuditChanges, error) {
+	encode := func(entity *T, column string) (json.RawMessage, error) {
+		if entity == nil {
+			return nil, nil
+		}
+		return json.Marshal(reflect.ValueOf(entity).Elem().Field(r.columns[column]).Interface())
+	}
+
+	changes := make(AuditChanges)
+	for _, column := range r.columnNames() {
+		old, err := encode(before, column)
+		if err != nil {
+			return nil, err
+		}
+		current, err := encode(after, column)
+		if err != nil {
+			return nil, err
+		}
+
+		if !bytes.Equal(old, current) {
+			changes[column] = FieldChange{Before: old, After: current}
+		}
+	}
+
+	return changes, nil
+}
+
+func (r *GenericRepository[I, T]) insertAudit(ctx context.Context, operation string, entries []AuditEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var actor any
+	if name, ok := ActorFromContext(ctx); ok {
+		actor = name
+	}
+
+	const params = 5
+	chunkSize := r.dialect.maxParams() / params
+	for start := 0; start < len(entries); start += chunkSize {
+		chunk := entries[start:min(start+chunkSize, len(entries))]
+
+		b := r.newSQLBuilder()
+		rows := make([]string, len(chunk))
+		for i, entry := range chunk {
+			rows[i] = fmt.Sprintf("(%s, %s, %s, %s, %s, %s)",
+				b.bind(entry.Table), b.bind(entry.EntityID), b.bind(string(entry.Operation)),
+				b.bind(actor), b.bind(entry.Changes), r.dialect.now())
+		}
+
+		query := fmt.Sprintf(
+			"INSERT INTO %s (table_name, entity_id, operation, actor, changes, created_at) VALUES %s",
+			r.dialect.quote(auditTable),
+			strings.Join(rows, ", "),
+		)
+		if _, err := r.conn(ctx, operation).ExecContext(ctx, query, b.args...); err != nil {
+			return wrapError("failed to write audit records", err)
+		}
+	}
+
+	return nil
+}
+
+// History lists the audit records of the entity, newest first. A limit
+// below 1 returns all of them.
+func (r *GenericRepository[I, T]) History(ctx context.Context, id string, limit, offset int) ([]AuditEntry, error) {
+	b := r.newSQLBuilder()
+	query := fmt.Sprintf(
+		"SELECT * FROM %s WHERE table_name = %s AND entity_id = %s ORDER BY id DESC",
+		r.dialect.quote(auditTable),
+		b.bind(r.tableName),
+		b.bind(id),
+	)
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	if offset > 0 {
+		query += fmt.Sprintf(" OFFSET %d", offset)
+	}
+
+	entries := []AuditEntry{}
+	if err := r.conn(ctx, "History").SelectContext(ctx, &entries, query, b.args...); err != nil {
+		return nil, wrapError("failed to read audit records", err)
+	}
+
+	return entries, nil
+}
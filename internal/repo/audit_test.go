@@ -0,0 +1,174 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/xligenda/ods-servers/internal/structs"
+)
+
+// operations returns the audited operations of the entity, oldest first,
+// with the columns each one changed.
+func operations(t *testing.T, r AuditTrail, id string) []string {
+	t.Helper()
+
+	entries, err := r.History(context.Background(), id, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := make([]string, len(entries))
+	for i, entry := range entries {
+		columns := make([]string, 0, len(entry.Changes))
+		for _, column := range []string{"id", "guild", "invite_channel", "roles", "version", "deleted_at"} {
+			if _, ok := entry.Changes[column]; ok {
+				columns = append(columns, column)
+			}
+		}
+		encoded, _ := json.Marshal(columns)
+		result[len(entries)-1-i] = string(entry.Operation) + " " + string(encoded)
+	}
+	return result
+}
+
+func TestAudit(t *testing.T) {
+	ctx := WithActor(context.Background(), "42")
+	server := func(tag int, guild structs.DiscordID) structs.Server {
+		return structs.Server{Tag: tag, Guild: guild, Roles: structs.ServerRoles{}}
+	}
+
+	tests := []struct {
+		name  string
+		write func(r *GenericRepository[string, structs.Server]) error
+		id    string
+		want  []string
+	}{
+		{
+			name: "create and merge patch",
+			write: func(r *GenericRepository[string, structs.Server]) error {
+				if _, err := r.Create(ctx, server(1, 10)); err != nil {
+					return err
+				}
+				_, err := r.MergePatch(ctx, "1", []byte(`{"roles": {"5": "Admin"}}`))
+				return err
+			},
+			id: "1",
+			want: []string{
+				`create ["id","guild","invite_channel","roles","version","deleted_at"]`,
+				`update ["roles","version"]`,
+			},
+		},
+		{
+			name: "create many and upsert many",
+			write: func(r *GenericRepository[string, structs.Server]) error {
+				if _, err := r.CreateMany(ctx, []structs.Server{server(1, 10), server(2, 20)}, nil); err != nil {
+					return err
+				}
+				_, err := r.UpsertMany(ctx, []structs.Server{server(1, 11), server(3, 30)}, nil, nil)
+				return err
+			},
+			id: "1",
+			want: []string{
+				`create ["id","guild","invite_channel","roles","version","deleted_at"]`,
+				`update ["guild","version"]`,
+			},
+		},
+		{
+			name: "upsert many inserting",
+			write: func(r *GenericRepository[string, structs.Server]) error {
+				_, err := r.UpsertMany(ctx, []structs.Server{server(3, 30)}, []string{"id"}, nil)
+				return err
+			},
+			id:   "3",
+			want: []string{`create ["id","guild","invite_channel","roles","version","deleted_at"]`},
+		},
+		{
+			name: "soft delete, restore and purge",
+			write: func(r *GenericRepository[string, structs.Server]) error {
+				if _, err := r.Create(ctx, server(1, 10)); err != nil {
+					return err
+				}
+				if err := r.Delete(ctx, "1"); err != nil {
+					return err
+				}
+				if _, err := r.Restore(ctx, "1"); err != nil {
+					return err
+				}
+				if _, err := r.DeleteMany(ctx, nil); err != nil {
+					return err
+				}
+				_, err := r.Purge(ctx, time.Now().Add(time.Hour))
+				return err
+			},
+			id: "1",
+			want: []string{
+				`create ["id","guild","invite_channel","roles","version","deleted_at"]`,
+				`delete ["deleted_at"]`,
+				`update ["deleted_at"]`,
+				`delete ["deleted_at"]`,
+				`delete ["id","guild","invite_channel","roles","version","deleted_at"]`,
+			},
+		},
+		{
+			name: "failed write",
+			write: func(r *GenericRepository[string, structs.Server]) error {
+				if _, err := r.Create(ctx, server(1, 10)); err != nil {
+					return err
+				}
+				if _, err := r.Update(ctx, "1", structs.Server{Tag: 1, Guild: 11, Version: 7}); err == nil {
+					t.Error("stale update succeeded")
+				}
+				return nil
+			},
+			id:   "1",
+			want: []string{`create ["id","guild","invite_channel","roles","version","deleted_at"]`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRepository[string, structs.Server](openTestDB(t), "servers", WithAudit())
+			if err := tt.write(r); err != nil {
+				t.Fatal(err)
+			}
+
+			got := operations(t, r, tt.id)
+			if len(got) != len(tt.want) {
+				t.Fatalf("history = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("record %d = %s, want %s", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAuditActor(t *testing.T) {
+	r := NewRepository[string, structs.Server](openTestDB(t), "servers", WithAudit())
+	if _, err := r.Create(WithActor(context.Background(), "42"), structs.Server{Tag: 1, Roles: structs.ServerRoles{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Delete(context.Background(), "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := r.History(context.Background(), "1", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Actor != nil || entries[1].Actor == nil || *entries[1].Actor != "42" {
+		t.Fatalf("entries = %+v", entries)
+	}
+
+	limited, err := r.History(context.Background(), "1", 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(limited) != 1 || limited[0].ID != entries[1].ID {
+		t.Errorf("limited = %+v", limited)
+	}
+}
@@ -0,0 +1,227 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+type BatchOptions struct {
+	// rows per statement, by default as many as fit under the parameter limit
+	ChunkSize int
+	// CreateMany only: skip rows violating a unique constraint, they are not returned
+	IgnoreConflicts bool
+}
+
+// CreateMany inserts the entities with multi-row INSERT statements in one
+// transaction and returns the inserted rows. Nil pointer fields take the
+// column default on Postgres, as in Create; SQLite does not accept DEFAULT
+// in a VALUES list, so they are NULL there.
+func (r *GenericRepository[I, T]) CreateMany(ctx context.Context, entities []T, opts *BatchOptions) ([]*T, error) {
+	suffix := ""
+	if opts != nil && opts.IgnoreConflicts {
+		suffix = r.dialect.onConflict(nil, nil, "")
+	}
+
+	var inserted []*T
+	err := r.audited(ctx, "CreateMany", nil, func(ctx context.Context) ([]*T, error) {
+		var err error
+		inserted, err = r.insertMany(ctx, "CreateMany", entities, opts, r.dialect.defaultValue(), suffix)
+		return inserted, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return inserted, nil
+}
+
+// UpsertMany inserts the entities or updates the rows conflicting on
+// conflictColumns ("id" by default). As in Upsert, nil pointer fields keep
+// the stored value; they are bound as NULL rather than the column default,
+// which COALESCE could not tell from a value, so inserted rows get NULL
+// there. Postgres rejects a statement touching the same row
+// twice, so the entities must not repeat a conflict key. With a version
+// column, a row whose stored version differs fails the whole batch with a
+// VersionConflictError listing the conflicting keys.
+func (r *GenericRepository[I, T]) UpsertMany(ctx context.Context, entities []T, conflictColumns []string, opts *BatchOptions) ([]*T, error) {
+	if len(conflictColumns) == 0 {
+		conflictColumns = []string{"id"}
+	}
+
+	conflicting := make(map[string]bool, len(conflictColumns))
+	quotedConflict := make([]string, len(conflictColumns))
+	for i, column := range conflictColumns {
+		if !r.hasColumn(column) {
+			return nil, &InvalidFilterError{Field: column, Reason: "unknown conflict column"}
+		}
+		conflicting[column] = true
+		quotedConflict[i] = r.dialect.quote(column)
+	}
+
+	var updates []string
+	entityType := reflect.TypeOf((*T)(nil)).Elem()
+	for _, column := range r.columnNames() {
+		if column == "id" || conflicting[column] {
+			continue
+		}
+		if column == versionColumn && r.versioned {
+			updates = append(updates, r.versionIncrement(true))
+			continue
+		}
+
+		quoted := r.dialect.quote(column)
+		if entityType.Field(r.columns[column]).Type.Kind() == reflect.Ptr {
+			updates = append(updates, fmt.Sprintf("%s = COALESCE(%s, %s.%s)", quoted, r.dialect.excluded(column), r.dialect.quote(r.tableName), quoted))
+		} else {
+			updates = append(updates, fmt.Sprintf("%s = %s", quoted, r.dialect.excluded(column)))
+		}
+	}
+
+	where := ""
+	if r.versioned {
+		where = fmt.Sprintf(
+			"%s.%s = %s",
+			r.dialect.quote(r.tableName),
+			r.dialect.quote(versionColumn),
+			r.dialect.excluded(versionColumn),
+		)
+	}
+	suffix := r.dialect.onConflict(quotedConflict, updates, where)
+
+	var upserted []*T
+	err := r.audited(ctx, "UpsertMany", r.keyScope(entities, conflictColumns), func(ctx context.Context) ([]*T, error) {
+		// the rows are written in a transaction of their own, so a conflict
+		// rolls the batch back without auditing as well
+		err := r.Transaction(ctx, nil, func(ctx context.Context) error {
+			var err error
+			upserted, err = r.insertMany(ctx, "UpsertMany", entities, opts, "NULL", suffix)
+			if err != nil || !r.versioned {
+				return err
+			}
+			return r.skippedError(entities, upserted, conflictColumns)
+		})
+		return upserted, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return upserted, nil
+}
+
+// insertMany inserts the entities in chunks, binding nilValue for nil pointer
+// fields and appending suffix to every statement.
+func (r *GenericRepository[I, T]) insertMany(ctx context.Context, operation string, entities []T, opts *BatchOptions, nilValue, suffix string) ([]*T, error) {
+	if len(entities) == 0 {
+		return nil, nil
+	}
+
+	columns := r.columnNames()
+	// a row with more columns than the limit fails in the database, not here
+	chunkSize := max(1, r.dialect.maxParams()/len(columns))
+	if opts != nil && opts.ChunkSize > 0 && opts.ChunkSize < chunkSize {
+		chunkSize = opts.ChunkSize
+	}
+
+	quotedColumns := make([]string, len(columns))
+	for i, column := range columns {
+		quotedColumns[i] = r.dialect.quote(column)
+	}
+
+	result := make([]*T, 0, len(entities))
+	err := r.Transaction(ctx, nil, func(ctx context.Context) error {
+		for start := 0; start < len(entities); start += chunkSize {
+			chunk := entities[start:min(start+chunkSize, len(entities))]
+
+			b := r.newSQLBuilder()
+			rows := make([]string, len(chunk))
+			for i, entity := range chunk {
+				rows[i] = "(" + strings.Join(r.bindRow(b, entity, columns, nilValue), ", ") + ")"
+			}
+
+			query := fmt.Sprintf(
+				"INSERT INTO %s (%s) VALUES %s%s%s",
+				r.dialect.quote(r.tableName),
+				strings.Join(quotedColumns, ", "),
+				strings.Join(rows, ", "),
+				suffix,
+				r.dialect.returning(),
+			)
+
+			var inserted []T
+			if err := r.conn(ctx, operation).SelectContext(ctx, &inserted, query, b.args...); err != nil {
+				return wrapError("failed to insert entities", err)
+			}
+			for i := range inserted {
+				result = append(result, &inserted[i])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// bindRow binds the column values of the entity, nil pointers become nilValue.
+func (r *GenericRepository[I, T]) bindRow(b *sqlBuilder, entity T, columns []string, nilValue string) []string {
+	v := reflect.ValueOf(entity)
+
+	values := make([]string, len(columns))
+	for i, column := range columns {
+		field := v.Field(r.columns[column])
+		if field.Kind() == reflect.Ptr && field.IsNil() {
+			values[i] = nilValue
+			continue
+		}
+		values[i] = b.bind(r.getFieldValue(field))
+	}
+
+	return values
+}
+
+// skippedError reports the entities the version check of an upsert left out
+// of the written rows.
+func (r *GenericRepository[I, T]) skippedError(entities []T, written []*T, conflictColumns []string) error {
+	writtenKeys := make(map[string]bool, len(written))
+	for _, entity := range written {
+		writtenKeys[r.conflictKey(*entity, conflictColumns)] = true
+	}
+
+	var conflict *VersionConflictError
+	for _, entity := range entities {
+		key := r.conflictKey(entity, conflictColumns)
+		if writtenKeys[key] {
+			continue
+		}
+		if conflict == nil {
+			conflict = &VersionConflictError{ID: fmt.Sprint(entity.GetID()), Expected: r.entityVersion(entity)}
+		}
+		conflict.Keys = append(conflict.Keys, key)
+	}
+
+	if conflict == nil {
+		return nil
+	}
+	return conflict
+}
+
+// conflictKey joins the values of the conflict columns as they are bound,
+// times in UTC so the zone of the returned rows does not matter.
+func (r *GenericRepository[I, T]) conflictKey(entity T, columns []string) string {
+	v := reflect.ValueOf(entity)
+	values := make([]string, len(columns))
+	for i, column := range columns {
+		value := r.getFieldValue(v.Field(r.columns[column]))
+		if t, ok := value.(time.Time); ok {
+			value = t.UTC().Format(time.RFC3339Nano)
+		}
+		values[i] = fmt.Sprint(value)
+	}
+	return strings.Join(values, ",")
+}
@@ -0,0 +1,116 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/xligenda/ods-servers/internal/structs"
+)
+
+func TestUpsertManyKeepsNilFields(t *testing.T) {
+	// SQLite runs the statements of both dialects, DEFAULT would be a syntax error
+	for _, dialect := range []Dialect{SQLite, Postgres} {
+		t.Run(dialect.Name(), func(t *testing.T) {
+			ctx := context.Background()
+			db := openTestDB(t)
+			db.MustExec("INSERT INTO servers (id, guild) VALUES (1, 10)")
+			r := NewRepository[string, structs.Invite](db, "invites", WithDialect(dialect))
+
+			used := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+			expires := time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC)
+			if _, err := r.Create(ctx, structs.Invite{Code: "a", Server: 1, ExpiresAt: expires, UsedAt: &used}); err != nil {
+				t.Fatal(err)
+			}
+
+			upserted, err := r.UpsertMany(ctx, []structs.Invite{
+				{Code: "a", Server: 1, Target: 5, ExpiresAt: expires},
+				{Code: "b", Server: 1, Target: 6, ExpiresAt: expires},
+			}, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(upserted) != 2 {
+				t.Fatalf("upserted %d rows, want 2", len(upserted))
+			}
+
+			for code, want := range map[string]*time.Time{"a": &used, "b": nil} {
+				invite, err := r.FindByID(ctx, code)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if (invite.UsedAt == nil) != (want == nil) || (want != nil && !invite.UsedAt.Equal(*want)) {
+					t.Errorf("%s: used_at = %v, want %v", code, invite.UsedAt, want)
+				}
+				if invite.Target == 0 {
+					t.Errorf("%s: target was not written", code)
+				}
+			}
+		})
+	}
+}
+
+func TestUpsertManyVersionConflict(t *testing.T) {
+	ctx := context.Background()
+	r := NewRepository[string, structs.Server](openTestDB(t), "servers")
+	for tag := 1; tag <= 3; tag++ {
+		if _, err := r.Create(ctx, structs.Server{Tag: tag, Guild: 10, Roles: structs.ServerRoles{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := r.Patch(ctx, "2", map[string]any{"guild": 20}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Patch(ctx, "3", map[string]any{"guild": 30}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 2 and 3 are at version 1, the batch still expects 0
+	batch := []structs.Server{
+		{Tag: 1, Guild: 11, Roles: structs.ServerRoles{}},
+		{Tag: 2, Guild: 21, Roles: structs.ServerRoles{}},
+		{Tag: 3, Guild: 31, Roles: structs.ServerRoles{}},
+	}
+	upserted, err := r.UpsertMany(ctx, batch, nil, nil)
+	var conflict *VersionConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("err = %v, want a VersionConflictError", err)
+	}
+	if upserted != nil || conflict.ID != "2" || fmt.Sprint(conflict.Keys) != "[2 3]" {
+		t.Errorf("upserted %v, conflict %+v", upserted, conflict)
+	}
+
+	// the batch was rolled back
+	server, err := r.FindByID(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.Guild != 10 || server.Version != 0 {
+		t.Errorf("server 1 = guild %d version %d, want it unchanged", server.Guild, server.Version)
+	}
+}
+
+// narrowDialect allows fewer parameters than an entity has columns.
+type narrowDialect struct {
+	Dialect
+}
+
+func (narrowDialect) maxParams() int {
+	return 2
+}
+
+func TestCreateManyNarrowParameterLimit(t *testing.T) {
+	r := NewRepository[string, structs.Server](openTestDB(t), "servers", WithDialect(narrowDialect{SQLite}))
+	created, err := r.CreateMany(context.Background(), []structs.Server{
+		{Tag: 1, Roles: structs.ServerRoles{}},
+		{Tag: 2, Roles: structs.ServerRoles{}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 2 {
+		t.Errorf("created %d rows, want 2", len(created))
+	}
+}
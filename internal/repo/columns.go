@@ -0,0 +1,47 @@
+package repo
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// entityColumns maps the db tags of the entity struct to their field index.
+func entityColumns(t reflect.Type) map[string]int {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	columns := make(map[string]int)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("db"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		columns[name] = i
+	}
+
+	return columns
+}
+
+func (r *GenericRepository[I, T]) hasColumn(name string) bool {
+	_, ok := r.columns[name]
+	return ok
+}
+
+// columnNames lists the columns in struct field order.
+func (r *GenericRepository[I, T]) columnNames() []string {
+	names := make([]string, 0, len(r.columns))
+	for name := range r.columns {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(a, b int) bool {
+		return r.columns[names[a]] < r.columns[names[b]]
+	})
+	return names
+}
@@ -0,0 +1,244 @@
+package repo
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// CursorQuery selects a page relative to a cursor returned by a previous page.
+type CursorQuery struct {
+	// cursor columns must be NOT NULL, "id" is appended as the tiebreaker
+	Sort  []SortKey
+	Limit int
+	// Page.Next of the previous page
+	After string
+	// Page.Prev of the previous page, mutually exclusive with After
+	Before string
+	// also count all rows matching the filters
+	Count   bool
+	Deleted DeletedScope
+}
+
+type Page[T any] struct {
+	Items []*T `json:"items"`
+	// empty when there is no page in that direction
+	Next  string `json:"next,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+	Total *int64 `json:"total,omitempty"`
+}
+
+type cursorPayload[V any] struct {
+	// sort signature, a cursor is only valid for the ordering it was issued for
+	Sort   string `json:"s"`
+	Values []V    `json:"v"`
+}
+
+// FindPage returns a page using keyset pagination: rows are located by the
+// sort values of the cursor row instead of an offset, so pages stay stable
+// when rows are inserted concurrently and no COUNT(*) is needed.
+func (r *GenericRepository[I, T]) FindPage(ctx context.Context, filters []Filter, q CursorQuery) (*Page[T], error) {
+	return r.findPage(ctx, filters, q, r.Find, r.Count)
+}
+
+// findPage implements keyset pagination on top of any find and count, so
+// every Repository implementation pages the same way.
+func (r *GenericRepository[I, T]) findPage(
+	ctx context.Context,
+	filters []Filter,
+	q CursorQuery,
+	find func(context.Context, []Filter, *QueryOptions) ([]*T, error),
+	count func(context.Context, []Filter, *QueryOptions) (int64, error),
+) (*Page[T], error) {
+	if q.Limit < 1 {
+		q.Limit = 10
+	}
+	if q.After != "" && q.Before != "" {
+		return nil, fmt.Errorf("%w: after and before are mutually exclusive", ErrInvalidCursor)
+	}
+
+	keys := slices.Clone(q.Sort)
+	if !slices.ContainsFunc(keys, func(key SortKey) bool { return key.Field == "id" }) {
+		keys = append(keys, Asc("id"))
+	}
+	for _, key := range keys {
+		if key.Nulls != NullsDefault {
+			return nil, &InvalidFilterError{Field: key.Field, Reason: "cursor columns cannot order nulls"}
+		}
+		if !r.hasColumn(key.Field) {
+			return nil, &InvalidFilterError{Field: key.Field, Reason: "unknown sort field"}
+		}
+	}
+	signature := sortSignature(keys)
+
+	backward := q.Before != ""
+	pageFilters := slices.Clone(filters)
+	if cursor := q.After + q.Before; cursor != "" {
+		values, err := r.decodeCursor(cursor, signature, keys)
+		if err != nil {
+			return nil, err
+		}
+		pageFilters = append(pageFilters, NewExprFilter(keysetExpr(keys, values, backward)))
+	}
+
+	queryKeys := keys
+	if backward {
+		queryKeys = reverseSort(keys)
+	}
+
+	items, err := find(ctx, pageFilters, &QueryOptions{Sort: queryKeys, Limit: q.Limit + 1, Deleted: q.Deleted})
+	if err != nil {
+		return nil, err
+	}
+
+	hasMore := len(items) > q.Limit
+	if hasMore {
+		items = items[:q.Limit]
+	}
+	if backward {
+		slices.Reverse(items)
+	}
+
+	page := &Page[T]{Items: items}
+	if len(items) > 0 {
+		// coming from a cursor means there is a page on the other side of it
+		hasNext, hasPrev := hasMore, q.After != ""
+		if backward {
+			hasNext, hasPrev = true, hasMore
+		}
+
+		if hasNext {
+			if page.Next, err = r.encodeCursor(items[len(items)-1], keys, signature); err != nil {
+				return nil, err
+			}
+		}
+		if hasPrev {
+			if page.Prev, err = r.encodeCursor(items[0], keys, signature); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if q.Count {
+		total, err := count(ctx, filters, &QueryOptions{Deleted: q.Deleted})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get total count: %w", err)
+		}
+		page.Total = &total
+	}
+
+	return page, nil
+}
+
+// keysetExpr matches the rows after the cursor values in the given ordering:
+// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ...
+func keysetExpr(keys []SortKey, values []any, backward bool) Expr {
+	branches := make([]Expr, len(keys))
+	for i, key := range keys {
+		conditions := make([]Expr, 0, i+1)
+		for j := range i {
+			conditions = append(conditions, Eq(keys[j].Field, values[j]))
+		}
+
+		if key.Desc != backward {
+			conditions = append(conditions, Lt(key.Field, values[i]))
+		} else {
+			conditions = append(conditions, Gt(key.Field, values[i]))
+		}
+		branches[i] = And(conditions...)
+	}
+
+	return Or(branches...)
+}
+
+func reverseSort(keys []SortKey) []SortKey {
+	reversed := make([]SortKey, len(keys))
+	for i, key := range keys {
+		key.Desc = !key.Desc
+		reversed[i] = key
+	}
+	return reversed
+}
+
+func sortSignature(keys []SortKey) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key.Field
+		if key.Desc {
+			parts[i] = "-" + key.Field
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func (r *GenericRepository[I, T]) encodeCursor(entity *T, keys []SortKey, signature string) (string, error) {
+	v := reflect.ValueOf(entity).Elem()
+
+	values := make([]any, len(keys))
+	for i, key := range keys {
+		values[i] = v.Field(r.columns[key.Field]).Interface()
+	}
+
+	payload, err := json.Marshal(cursorPayload[any]{Sort: signature, Values: values})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(r.signCursor(encoded)), nil
+}
+
+// decodeCursor returns the values of the cursor as the types of their
+// fields, bound like the fields themselves.
+func (r *GenericRepository[I, T]) decodeCursor(cursor, signature string, keys []SortKey) ([]any, error) {
+	encoded, sig, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, r.signCursor(encoded)) {
+		return nil, ErrInvalidCursor
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var payload cursorPayload[json.RawMessage]
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if payload.Sort != signature || len(payload.Values) != len(keys) {
+		return nil, fmt.Errorf("%w: cursor was issued for another ordering", ErrInvalidCursor)
+	}
+
+	entityType := reflect.TypeOf((*T)(nil)).Elem()
+	values := make([]any, len(keys))
+	for i, key := range keys {
+		// a time is decoded as time.Time rather than its RFC 3339 text
+		value := reflect.New(entityType.Field(r.columns[key.Field]).Type)
+		if err := json.Unmarshal(payload.Values[i], value.Interface()); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		values[i] = r.getFieldValue(value.Elem())
+	}
+
+	return values, nil
+}
+
+func (r *GenericRepository[I, T]) signCursor(encoded string) []byte {
+	mac := hmac.New(sha256.New, r.options.cursorSecret)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,136 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/xligenda/ods-servers/internal/structs"
+)
+
+// inviteRepositories returns both implementations with the same invites,
+// their expiry times written in different zones.
+func inviteRepositories(t *testing.T, n int) map[string]Repository[string, structs.Invite] {
+	t.Helper()
+
+	db := openTestDB(t)
+	db.MustExec("INSERT INTO servers (id, guild) VALUES (1, 10)")
+	repositories := map[string]Repository[string, structs.Invite]{
+		"sql":    NewRepository[string, structs.Invite](db, "invites"),
+		"memory": NewMemoryRepository[string, structs.Invite]("invites"),
+	}
+
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	zones := []*time.Location{time.UTC, time.FixedZone("UTC+3", 3*3600), time.FixedZone("UTC-5", -5*3600)}
+	for _, r := range repositories {
+		for i := 0; i < n; i++ {
+			invite := structs.Invite{
+				Code:      fmt.Sprintf("code%02d", i),
+				Server:    1,
+				ExpiresAt: base.Add(time.Duration(i) * 90 * time.Minute).In(zones[i%len(zones)]),
+				CreatedAt: base,
+			}
+			if _, err := r.Create(context.Background(), invite); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return repositories
+}
+
+func codes(invites []*structs.Invite) string {
+	s := ""
+	for _, invite := range invites {
+		s += invite.Code[4:] + " "
+	}
+	return s
+}
+
+func TestFindPageByTime(t *testing.T) {
+	for name, r := range inviteRepositories(t, 7) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			q := CursorQuery{Sort: []SortKey{Desc("expires_at")}, Limit: 3, Count: true}
+
+			var pages []string
+			var last *Page[structs.Invite]
+			for {
+				page, err := r.FindPage(ctx, nil, q)
+				if err != nil {
+					t.Fatal(err)
+				}
+				pages = append(pages, codes(page.Items))
+				last = page
+				if page.Next == "" {
+					break
+				}
+				q.After = page.Next
+			}
+
+			want := []string{"06 05 04 ", "03 02 01 ", "00 "}
+			if fmt.Sprint(pages) != fmt.Sprint(want) {
+				t.Errorf("pages = %q, want %q", pages, want)
+			}
+			if last.Total == nil || *last.Total != 7 {
+				t.Errorf("total = %v", last.Total)
+			}
+
+			back, err := r.FindPage(ctx, nil, CursorQuery{Sort: q.Sort, Limit: 3, Before: last.Prev})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := codes(back.Items); got != "03 02 01 " || back.Next == "" || back.Prev == "" {
+				t.Errorf("previous page = %q, next %q, prev %q", got, back.Next, back.Prev)
+			}
+		})
+	}
+}
+
+func TestFindPageInvalidCursor(t *testing.T) {
+	for name, r := range inviteRepositories(t, 3) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			page, err := r.FindPage(ctx, nil, CursorQuery{Sort: []SortKey{Asc("expires_at")}, Limit: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tests := map[string]CursorQuery{
+				"tampered":       {Sort: []SortKey{Asc("expires_at")}, After: "x" + page.Next},
+				"other ordering": {Sort: []SortKey{Desc("expires_at")}, After: page.Next},
+				"both cursors":   {Sort: []SortKey{Asc("expires_at")}, After: page.Next, Before: page.Next},
+				"malformed":      {After: "not a cursor"},
+			}
+			for name, q := range tests {
+				if _, err := r.FindPage(ctx, nil, q); !errors.Is(err, ErrInvalidCursor) {
+					t.Errorf("%s: err = %v, want ErrInvalidCursor", name, err)
+				}
+			}
+
+			if _, err := r.FindPage(ctx, nil, CursorQuery{Sort: []SortKey{{Field: "expires_at", Nulls: NullsFirst}}}); !errors.Is(err, ErrInvalidFilter) {
+				t.Errorf("nulls ordering: err = %v, want ErrInvalidFilter", err)
+			}
+		})
+	}
+}
+
+func TestCursorFromAnotherRepository(t *testing.T) {
+	db := openTestDB(t)
+	a := NewRepository[string, structs.Server](db, "servers", WithCursorSecret([]byte("a")))
+	b := NewRepository[string, structs.Server](db, "servers", WithCursorSecret([]byte("b")))
+	for tag := 1; tag <= 2; tag++ {
+		if _, err := a.Create(context.Background(), structs.Server{Tag: tag, Roles: structs.ServerRoles{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	page, err := a.FindPage(context.Background(), nil, CursorQuery{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.FindPage(context.Background(), nil, CursorQuery{Limit: 1, After: page.Next}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("err = %v, want ErrInvalidCursor", err)
+	}
+}
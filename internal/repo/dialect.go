@@ -0,0 +1,245 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Dialect renders the parts of a statement that differ between databases.
+// NewRepository picks it from the driver name of the connection, WithDialect
+// overrides the detection.
+type Dialect interface {
+	Name() string
+	quote(identifier string) string
+	// placeholder of the n-th argument, starting at 1
+	placeholder(n int) string
+	now() string
+	// appended to INSERT and UPDATE to read back the written rows
+	returning() string
+	// appended to a SELECT to lock the rows until the transaction ends
+	forUpdate() string
+	// column LIKE, NOT LIKE, ILIKE or NOT ILIKE pattern
+	like(column, operator, pattern string) string
+	// ON CONFLICT clause of an INSERT: DO NOTHING without updates, a nil
+	// target ignores conflicts on any unique constraint
+	onConflict(target, updates []string, where string) string
+	// excluded references the value an INSERT proposed for the column
+	excluded(column string) string
+	// number of bind parameters accepted in one statement
+	maxParams() int
+	// value inserted for omitted columns of a multi-row INSERT
+	defaultValue() string
+	// the JSONB operators of the jsonb filters
+	jsonb() bool
+	// bindValue converts an argument into the representation the database
+	// stores and compares
+	bindValue(value any) any
+	// columns of the table, none when it does not exist
+	tableColumns(ctx context.Context, q querier, table string) ([]tableColumn, error)
+	// lower-case column types a field of type t can be stored in, nil accepts any
+	columnTypes(t reflect.Type) []string
+}
+
+var (
+	Postgres Dialect = postgresDialect{}
+	// SQLite needs case_sensitive_like, sqlite.Open enables it.
+	SQLite Dialect = sqliteDialect{}
+)
+
+// WithDialect sets the SQL dialect instead of detecting it from the driver.
+func WithDialect(dialect Dialect) Option {
+	return func(o *options) {
+		o.dialect = dialect
+	}
+}
+
+func dialectFor(driverName string) Dialect {
+	switch driverName {
+	case "sqlite", "sqlite3":
+		return SQLite
+	default:
+		return Postgres
+	}
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string {
+	return "postgres"
+}
+
+func (postgresDialect) quote(identifier string) string {
+	return pq.QuoteIdentifier(identifier)
+}
+
+func (postgresDialect) placeholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+func (postgresDialect) now() string {
+	return "NOW()"
+}
+
+func (postgresDialect) returning() string {
+	return " RETURNING *"
+}
+
+func (postgresDialect) forUpdate() string {
+	return " FOR UPDATE"
+}
+
+func (postgresDialect) like(column, operator, pattern string) string {
+	return fmt.Sprintf("%s %s %s", column, operator, pattern)
+}
+
+func (postgresDialect) onConflict(target, updates []string, where string) string {
+	return onConflictClause(target, updates, where)
+}
+
+func (d postgresDialect) excluded(column string) string {
+	return "EXCLUDED." + d.quote(column)
+}
+
+func (postgresDialect) maxParams() int {
+	return 65535
+}
+
+func (postgresDialect) defaultValue() string {
+	return "DEFAULT"
+}
+
+func (postgresDialect) jsonb() bool {
+	return true
+}
+
+func (postgresDialect) bindValue(value any) any {
+	return value
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string {
+	return "sqlite"
+}
+
+func (sqliteDialect) quote(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
+
+// placeholder numbers the argument explicitly, $n would be a named parameter.
+func (sqliteDialect) placeholder(n int) string {
+	return fmt.Sprintf("?%d", n)
+}
+
+// sqliteTimeFormat stores times as UTC text with a fixed number of digits,
+// so they compare and sort as text.
+const sqliteTimeFormat = "2006-01-02 15:04:05.000000000+00:00"
+
+// now is in sqliteTimeFormat, strftime only has milliseconds.
+func (sqliteDialect) now() string {
+	return "strftime('%Y-%m-%d %H:%M:%f', 'now') || '000000+00:00'"
+}
+
+func (sqliteDialect) returning() string {
+	return " RETURNING *"
+}
+
+// forUpdate is empty, SQLite locks the whole database on the first write.
+func (sqliteDialect) forUpdate() string {
+	return ""
+}
+
+// like has no ILIKE to map to, case-insensitive patterns compare lowered
+// operands. Unlike Postgres, SQLite has no default escape character.
+func (sqliteDialect) like(column, operator, pattern string) string {
+	negated := strings.HasPrefix(operator, "NOT ")
+	if strings.HasSuffix(operator, "ILIKE") {
+		column, pattern = "LOWER("+column+")", "LOWER("+pattern+")"
+	}
+
+	operator = "LIKE"
+	if negated {
+		operator = "NOT LIKE"
+	}
+	return fmt.Sprintf(`%s %s %s ESCAPE '\'`, column, operator, pattern)
+}
+
+func (sqliteDialect) onConflict(target, updates []string, where string) string {
+	return onConflictClause(target, updates, where)
+}
+
+func (d sqliteDialect) excluded(column string) string {
+	return "excluded." + d.quote(column)
+}
+
+func (sqliteDialect) maxParams() int {
+	return 32766
+}
+
+// defaultValue is NULL, SQLite does not accept DEFAULT in VALUES.
+func (sqliteDialect) defaultValue() string {
+	return "NULL"
+}
+
+func (sqliteDialect) jsonb() bool {
+	return false
+}
+
+func (sqliteDialect) bindValue(value any) any {
+	if t, ok := value.(time.Time); ok {
+		return t.UTC().Format(sqliteTimeFormat)
+	}
+	return value
+}
+
+// onConflictClause is the upsert syntax Postgres and SQLite 3.24+ share.
+func onConflictClause(target, updates []string, where string) string {
+	clause := " ON CONFLICT"
+	if len(target) > 0 {
+		clause += " (" + strings.Join(target, ", ") + ")"
+	}
+	if len(updates) == 0 {
+		return clause + " DO NOTHING"
+	}
+
+	clause += " DO UPDATE SET " + strings.Join(updates, ", ")
+	if where != "" {
+		clause += " WHERE " + where
+	}
+	return clause
+}
+
+// sqliteError is implemented by the errors of the SQLite driver, Code
+// returns the extended result code.
+type sqliteError interface {
+	error
+	Code() int
+}
+
+// classifySQLiteError maps the constraint violations of SQLite onto the
+// errors of the Postgres SQLSTATE codes.
+func classifySQLiteError(err error) error {
+	var sqliteErr sqliteError
+	if !errors.As(err, &sqliteErr) {
+		return nil
+	}
+
+	switch sqliteErr.Code() {
+	case 1555, 2067: // SQLITE_CONSTRAINT_PRIMARYKEY, SQLITE_CONSTRAINT_UNIQUE
+		return ErrConflict
+	case 787: // SQLITE_CONSTRAINT_FOREIGNKEY
+		return ErrForeignKey
+	case 275: // SQLITE_CONSTRAINT_CHECK
+		return ErrCheckViolation
+	case 1299: // SQLITE_CONSTRAINT_NOTNULL
+		return ErrNotNull
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,105 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/xligenda/ods-servers/pkg/apierrors"
+)
+
+var (
+	ErrNotFound       = errors.New("entity not found")
+	ErrConflict       = errors.New("unique constraint violation")
+	ErrForeignKey     = errors.New("foreign key violation")
+	ErrCheckViolation = errors.New("check constraint violation")
+	ErrNotNull        = errors.New("not null violation")
+	// any other failure of the database or the connection
+	ErrDatabase = errors.New("database error")
+
+	ErrInvalidFilter = errors.New("invalid filter")
+)
+
+// InvalidFilterError rejects a filter referencing an unknown column or operator.
+type InvalidFilterError struct {
+	Field    string
+	Operator string
+	Reason   string
+}
+
+func (e *InvalidFilterError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("invalid filter: %s", e.Reason)
+	}
+	return fmt.Sprintf("invalid filter on %q: %s", e.Field, e.Reason)
+}
+
+func (e *InvalidFilterError) Unwrap() error {
+	return ErrInvalidFilter
+}
+
+// wrapError classifies a database error by its SQLSTATE or SQLite result code, the original
+// error stays in the chain for callers needing the constraint details.
+func wrapError(message string, err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s: %w", message, ErrNotFound)
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%s: %w", message, err)
+	}
+
+	if classified := classifySQLiteError(err); classified != nil {
+		return fmt.Errorf("%s: %w: %w", message, classified, err)
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "23505":
+			return fmt.Errorf("%s: %w: %w", message, ErrConflict, err)
+		case "23503":
+			return fmt.Errorf("%s: %w: %w", message, ErrForeignKey, err)
+		case "23514":
+			return fmt.Errorf("%s: %w: %w", message, ErrCheckViolation, err)
+		case "23502":
+			return fmt.Errorf("%s: %w: %w", message, ErrNotNull, err)
+		}
+	}
+
+	return fmt.Errorf("%s: %w: %w", message, ErrDatabase, err)
+}
+
+// ToAPIError translates repository errors into API errors for the fiber
+// ErrorHandler, any other error is returned unchanged.
+func ToAPIError(err error) error {
+	var filterErr *InvalidFilterError
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &filterErr):
+		return apierrors.ErrBadRequest.With(filterErr.Error())
+	case errors.Is(err, ErrInvalidPatch):
+		return apierrors.ErrValidationFailed.With(err.Error())
+	case errors.Is(err, ErrInvalidCursor):
+		return apierrors.ErrBadRequest.With("Invalid cursor")
+	case errors.Is(err, ErrNotFound):
+		return apierrors.ErrRecordNotFound
+	case errors.Is(err, ErrVersionConflict):
+		return apierrors.ErrConflict.With("Entity was modified, reload it and retry")
+	case errors.Is(err, ErrConflict):
+		return apierrors.ErrDuplicateEntry
+	case errors.Is(err, ErrForeignKey):
+		return apierrors.ErrConflict.With("Referenced record does not exist or is still referenced")
+	case errors.Is(err, ErrCheckViolation), errors.Is(err, ErrNotNull):
+		return apierrors.ErrValidationFailed
+	case errors.Is(err, context.DeadlineExceeded):
+		return apierrors.ErrGatewayTimeout
+	case errors.Is(err, ErrDatabase):
+		return apierrors.ErrDatabaseError
+	}
+
+	return err
+}
@@ -0,0 +1,589 @@
+package repo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tri is the result of a condition under the three-valued logic of SQL,
+// comparisons with NULL are unknown and unknown rows never match.
+type tri int8
+
+const (
+	triFalse tri = iota
+	triTrue
+	triUnknown
+)
+
+func triOf(b bool) tri {
+	if b {
+		return triTrue
+	}
+	return triFalse
+}
+
+// not negates the value, the negation of unknown stays unknown.
+func (t tri) not() tri {
+	switch t {
+	case triTrue:
+		return triFalse
+	case triFalse:
+		return triTrue
+	default:
+		return triUnknown
+	}
+}
+
+// evalRow is an entity expressions are evaluated against in memory.
+type evalRow struct {
+	value   reflect.Value
+	columns map[string]int
+	// columns compared as JSON documents
+	jsonColumns map[string]bool
+}
+
+// column returns the value the database would store for the column, NULL
+// for nil pointers as getFieldValue binds them. A zero time is a value.
+func (row evalRow) column(field string) (any, bool, error) {
+	index, ok := row.columns[field]
+	if !ok {
+		return nil, false, &InvalidFilterError{Field: field, Reason: "unknown field"}
+	}
+
+	value := row.value.Field(index)
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil, true, nil
+		}
+		value = value.Elem()
+	}
+	if row.jsonColumns[field] {
+		document, err := decodeJSON(value.Interface())
+		return document, false, err
+	}
+	return value.Interface(), false, nil
+}
+
+func (e andExpr) eval(row evalRow) (tri, error) {
+	result := triTrue
+	for _, expr := range e {
+		if expr == nil {
+			return triFalse, &InvalidFilterError{Reason: "nil expression"}
+		}
+
+		value, err := expr.eval(row)
+		if err != nil {
+			return triFalse, err
+		}
+		if value == triFalse {
+			return triFalse, nil
+		}
+		if value == triUnknown {
+			result = triUnknown
+		}
+	}
+	return result, nil
+}
+
+func (e orExpr) eval(row evalRow) (tri, error) {
+	result := triFalse
+	for _, expr := range e {
+		if expr == nil {
+			return triFalse, &InvalidFilterError{Reason: "nil expression"}
+		}
+
+		value, err := expr.eval(row)
+		if err != nil {
+			return triFalse, err
+		}
+		if value == triTrue {
+			return triTrue, nil
+		}
+		if value == triUnknown {
+			result = triUnknown
+		}
+	}
+	return result, nil
+}
+
+func (e notExpr) eval(row evalRow) (tri, error) {
+	if e.expr == nil {
+		return triFalse, &InvalidFilterError{Operator: "NOT", Reason: "nil expression"}
+	}
+
+	value, err := e.expr.eval(row)
+	if err != nil {
+		return triFalse, err
+	}
+	return value.not(), nil
+}
+
+func (f Filter) eval(row evalRow) (tri, error) {
+	operator := strings.Join(strings.Fields(strings.ToUpper(f.Operator)), " ")
+
+	switch operator {
+	case "EXPR":
+		expr, ok := f.Value.(Expr)
+		if !ok || expr == nil {
+			return triFalse, &InvalidFilterError{Operator: operator, Reason: fmt.Sprintf("expected an expression, got %T", f.Value)}
+		}
+		return expr.eval(row)
+
+	case "RAW":
+		return triFalse, fmt.Errorf("raw SQL filters: %w", errors.ErrUnsupported)
+	}
+
+	value, null, err := row.column(f.Field)
+	if err != nil {
+		return triFalse, err
+	}
+
+	switch operator {
+	case "IS NULL":
+		return triOf(null), nil
+
+	case "IS NOT NULL":
+		return triOf(!null), nil
+
+	case "IN", "NOT IN":
+		values, err := sliceValues(f.Value)
+		if err != nil {
+			return triFalse, &InvalidFilterError{Field: f.Field, Operator: operator, Reason: err.Error()}
+		}
+		// as in SQL, an empty list matches nothing, or everything when negated
+		if len(values) == 0 {
+			return triOf(operator == "NOT IN"), nil
+		}
+		if null {
+			return triUnknown, nil
+		}
+
+		// x IN (a, b) is x = a OR x = b, x NOT IN (a, b) is its negation
+		result := triFalse
+		for _, candidate := range values {
+			if candidate == nil {
+				result = triUnknown
+				continue
+			}
+			cmp, err := compareValues(value, candidate)
+			if err != nil {
+				return triFalse, err
+			}
+			if cmp == 0 {
+				result = triTrue
+				break
+			}
+		}
+		if operator == "NOT IN" {
+			return result.not(), nil
+		}
+		return result, nil
+
+	case "@>", "?", "?|", "?&":
+		if null {
+			return triUnknown, nil
+		}
+		return evalJSONCondition(value, f, operator)
+
+	default:
+		if null || f.Value == nil {
+			return triUnknown, nil
+		}
+		return compareOperator(operator, value, f.Value)
+	}
+}
+
+func (e jsonPathExpr) eval(row evalRow) (tri, error) {
+	document, null, err := row.column(e.field)
+	if err != nil || null {
+		return triUnknown, err
+	}
+
+	// #>> yields NULL for missing paths and JSON null
+	for _, key := range e.path {
+		switch node := document.(type) {
+		case map[string]any:
+			document = node[key]
+		case []any:
+			index, err := strconv.Atoi(key)
+			if index < 0 {
+				index += len(node)
+			}
+			if err != nil || index < 0 || index >= len(node) {
+				return triUnknown, nil
+			}
+			document = node[index]
+		default:
+			return triUnknown, nil
+		}
+	}
+	if document == nil || e.value == nil {
+		return triUnknown, nil
+	}
+
+	text, ok := document.(string)
+	if !ok {
+		encoded, err := json.Marshal(document)
+		if err != nil {
+			return triFalse, err
+		}
+		text = string(encoded)
+	}
+
+	// the extracted text is cast like the SQL builder casts it
+	var extracted any = text
+	switch sqlScalar(e.value).(type) {
+	case *big.Rat:
+		if extracted, err = toRat(text); err != nil {
+			return triFalse, err
+		}
+	case bool:
+		if extracted, err = toBool(text); err != nil {
+			return triFalse, err
+		}
+	}
+
+	return compareOperator(e.operator, extracted, e.value)
+}
+
+func (e jsonPathExistsExpr) eval(row evalRow) (tri, error) {
+	return triFalse, fmt.Errorf("jsonpath filters: %w", errors.ErrUnsupported)
+}
+
+// compareOperator applies one of the comparisonOperators to non-NULL operands.
+func compareOperator(operator string, left, right any) (tri, error) {
+	switch operator {
+	case "LIKE", "NOT LIKE", "ILIKE", "NOT ILIKE":
+		pattern, err := likePattern(toText(sqlScalar(right)), strings.HasSuffix(operator, "ILIKE"))
+		if err != nil {
+			return triFalse, err
+		}
+		matched := pattern.MatchString(toText(sqlScalar(left)))
+		return triOf(matched != strings.HasPrefix(operator, "NOT")), nil
+	}
+
+	cmp, err := compareValues(left, right)
+	if err != nil {
+		return triFalse, err
+	}
+
+	switch operator {
+	case "=":
+		return triOf(cmp == 0), nil
+	case "!=", "<>":
+		return triOf(cmp != 0), nil
+	case ">":
+		return triOf(cmp > 0), nil
+	case "<":
+		return triOf(cmp < 0), nil
+	case ">=":
+		return triOf(cmp >= 0), nil
+	case "<=":
+		return triOf(cmp <= 0), nil
+	default:
+		return triFalse, &InvalidFilterError{Operator: operator, Reason: "unsupported operator"}
+	}
+}
+
+// compareValues orders a column value and a non-NULL operand, the operand
+// is coerced to the type of the column as Postgres does for parameters.
+func compareValues(column, operand any) (int, error) {
+	switch left := sqlScalar(column).(type) {
+	case *big.Rat:
+		right, err := toRat(sqlScalar(operand))
+		if err != nil {
+			return 0, err
+		}
+		return left.Cmp(right), nil
+
+	case bool:
+		right, err := toBool(sqlScalar(operand))
+		if err != nil {
+			return 0, err
+		}
+		if left == right {
+			return 0, nil
+		}
+		if right {
+			return -1, nil
+		}
+		return 1, nil
+
+	case time.Time:
+		right, err := toTime(sqlScalar(operand))
+		if err != nil {
+			return 0, err
+		}
+		return left.Compare(right), nil
+
+	case string:
+		return strings.Compare(left, toText(sqlScalar(operand))), nil
+
+	default:
+		return 0, fmt.Errorf("cannot compare values of type %T", column)
+	}
+}
+
+// sqlScalar reduces a value to *big.Rat, bool, time.Time or string.
+func sqlScalar(value any) any {
+	switch value := value.(type) {
+	case time.Time:
+		return value
+	case *time.Time:
+		if value != nil {
+			return *value
+		}
+	case []byte:
+		return string(value)
+	case json.Number:
+		return value.String()
+	}
+
+	v := reflect.ValueOf(value)
+	switch {
+	case !v.IsValid():
+		return ""
+	case v.Kind() == reflect.Ptr:
+		if v.IsNil() {
+			return ""
+		}
+		return sqlScalar(v.Elem().Interface())
+	case v.CanInt():
+		return new(big.Rat).SetInt64(v.Int())
+	case v.CanUint():
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(v.Uint()))
+	case v.CanFloat():
+		if rat := new(big.Rat).SetFloat64(v.Float()); rat != nil {
+			return rat
+		}
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	case v.Kind() == reflect.Bool:
+		return v.Bool()
+	case v.Kind() == reflect.String:
+		return v.String()
+	}
+
+	// documents are compared by their JSON text
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
+
+func toRat(value any) (*big.Rat, error) {
+	switch value := value.(type) {
+	case *big.Rat:
+		return value, nil
+	case string:
+		if rat, ok := new(big.Rat).SetString(strings.TrimSpace(value)); ok {
+			return rat, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid input syntax for type numeric: %q", toText(value))
+}
+
+func toBool(value any) (bool, error) {
+	switch value := value.(type) {
+	case bool:
+		return value, nil
+	case string:
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "t", "true", "y", "yes", "on", "1":
+			return true, nil
+		case "f", "false", "n", "no", "off", "0":
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("invalid input syntax for type boolean: %q", toText(value))
+}
+
+func toTime(value any) (time.Time, error) {
+	switch value := value.(type) {
+	case time.Time:
+		return value, nil
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(value)); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid input syntax for type timestamp: %q", toText(value))
+}
+
+func toText(value any) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case *big.Rat:
+		if value.IsInt() {
+			return value.Num().String()
+		}
+		f, _ := value.Float64()
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	case time.Time:
+		return value.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// likePattern translates a LIKE pattern, % and _ are wildcards and a
+// backslash escapes the next character.
+func likePattern(pattern string, fold bool) (*regexp.Regexp, error) {
+	var expr strings.Builder
+	expr.WriteString("^(?s)")
+	if fold {
+		expr.WriteString("(?i)")
+	}
+
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			expr.WriteString(".*")
+		case r == '_':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	if escaped {
+		return nil, fmt.Errorf("LIKE pattern must not end with escape character")
+	}
+
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
+
+func decodeJSON(value any) (any, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var document any
+	if err := json.Unmarshal(encoded, &document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+func evalJSONCondition(document any, f Filter, operator string) (tri, error) {
+	switch operator {
+	case "@>":
+		value, err := decodeJSON(f.Value)
+		if err != nil {
+			return triFalse, &InvalidFilterError{Field: f.Field, Operator: operator, Reason: err.Error()}
+		}
+		return triOf(jsonContains(document, value, true)), nil
+
+	case "?":
+		key, ok := f.Value.(string)
+		if !ok {
+			return triFalse, &InvalidFilterError{Field: f.Field, Operator: operator, Reason: "expected a string key"}
+		}
+		return triOf(jsonHasKey(document, key)), nil
+
+	default:
+		values, err := sliceValues(f.Value)
+		if err != nil {
+			return triFalse, &InvalidFilterError{Field: f.Field, Operator: operator, Reason: err.Error()}
+		}
+
+		// ?| needs any key, ?& all of them
+		all := operator == "?&"
+		for _, value := range values {
+			if jsonHasKey(document, fmt.Sprint(value)) != all {
+				return triOf(!all), nil
+			}
+		}
+		return triOf(all), nil
+	}
+}
+
+// jsonContains implements the jsonb @> operator.
+func jsonContains(document, value any, top bool) bool {
+	switch document := document.(type) {
+	case map[string]any:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return false
+		}
+		for key, member := range object {
+			current, ok := document[key]
+			if !ok || !jsonContains(current, member, false) {
+				return false
+			}
+		}
+		return true
+
+	case []any:
+		elements, ok := value.([]any)
+		if !ok {
+			// only a top-level array may contain a bare scalar
+			if !top || isJSONContainer(value) {
+				return false
+			}
+			elements = []any{value}
+		}
+		for _, element := range elements {
+			found := false
+			for _, current := range document {
+				if jsonContains(current, element, false) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+
+	default:
+		return !isJSONContainer(value) && document == value
+	}
+}
+
+func isJSONContainer(value any) bool {
+	switch value.(type) {
+	case map[string]any, []any:
+		return true
+	default:
+		return false
+	}
+}
+
+// jsonHasKey implements the jsonb ? operator.
+func jsonHasKey(document any, key string) bool {
+	switch document := document.(type) {
+	case map[string]any:
+		_, ok := document[key]
+		return ok
+	case []any:
+		for _, element := range document {
+			if element == key {
+				return true
+			}
+		}
+		return false
+	case string:
+		return document == key
+	default:
+		return false
+	}
+}
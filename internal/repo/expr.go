@@ -0,0 +1,245 @@
+package repo
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Expr is a node of a boolean filter expression. Expressions are compiled
+// into parameterised SQL, every value is bound as a placeholder, or
+// evaluated against entities by MemoryRepository.
+//
+//	repo.And(
+//		repo.Or(repo.In("id", 1, 2, 3), repo.ILike("name", "%black%")),
+//		repo.Not(repo.Eq("deleted", true)),
+//	)
+type Expr interface {
+	build(b *sqlBuilder) (string, error)
+	eval(row evalRow) (tri, error)
+}
+
+// comparisonOperators are the operators a Filter may use verbatim in SQL.
+var comparisonOperators = map[string]bool{
+	"=": true, "!=": true, "<>": true, ">": true, "<": true, ">=": true, "<=": true,
+	"LIKE": true, "NOT LIKE": true, "ILIKE": true, "NOT ILIKE": true,
+}
+
+// sqlBuilder collects the arguments of a query and numbers their placeholders.
+type sqlBuilder struct {
+	args []any
+	// known columns, filters on any other field are rejected
+	columns map[string]int
+	// columns accepting the JSONB operators
+	jsonColumns map[string]bool
+	dialect     Dialect
+}
+
+func (r *GenericRepository[I, T]) newSQLBuilder(args ...any) *sqlBuilder {
+	return &sqlBuilder{args: args, columns: r.columns, jsonColumns: r.jsonColumns, dialect: r.dialect}
+}
+
+func (b *sqlBuilder) column(field, operator string) (string, error) {
+	if _, ok := b.columns[field]; !ok {
+		return "", &InvalidFilterError{Field: field, Operator: operator, Reason: "unknown field"}
+	}
+	return b.dialect.quote(field), nil
+}
+
+func (b *sqlBuilder) bind(value any) string {
+	b.args = append(b.args, b.dialect.bindValue(value))
+	return b.dialect.placeholder(len(b.args))
+}
+
+type andExpr []Expr
+
+type orExpr []Expr
+
+type notExpr struct {
+	expr Expr
+}
+
+func And(exprs ...Expr) Expr {
+	return andExpr(exprs)
+}
+
+func Or(exprs ...Expr) Expr {
+	return orExpr(exprs)
+}
+
+func Not(expr Expr) Expr {
+	return notExpr{expr: expr}
+}
+
+func (e andExpr) build(b *sqlBuilder) (string, error) {
+	if len(e) == 0 {
+		return "TRUE", nil
+	}
+	return joinExprs(b, e, " AND ")
+}
+
+func (e orExpr) build(b *sqlBuilder) (string, error) {
+	if len(e) == 0 {
+		return "FALSE", nil
+	}
+	return joinExprs(b, e, " OR ")
+}
+
+func (e notExpr) build(b *sqlBuilder) (string, error) {
+	if e.expr == nil {
+		return "", &InvalidFilterError{Operator: "NOT", Reason: "nil expression"}
+	}
+
+	condition, err := e.expr.build(b)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("NOT (%s)", condition), nil
+}
+
+func joinExprs(b *sqlBuilder, exprs []Expr, separator string) (string, error) {
+	parts := make([]string, 0, len(exprs))
+	for _, expr := range exprs {
+		if expr == nil {
+			return "", &InvalidFilterError{Reason: "nil expression"}
+		}
+
+		part, err := expr.build(b)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, part)
+	}
+
+	return "(" + strings.Join(parts, separator) + ")", nil
+}
+
+func Eq(field string, value any) Expr {
+	return NewFilter(field, "=", value)
+}
+
+func Ne(field string, value any) Expr {
+	return NewFilter(field, "!=", value)
+}
+
+func Gt(field string, value any) Expr {
+	return NewFilter(field, ">", value)
+}
+
+func Gte(field string, value any) Expr {
+	return NewFilter(field, ">=", value)
+}
+
+func Lt(field string, value any) Expr {
+	return NewFilter(field, "<", value)
+}
+
+func Lte(field string, value any) Expr {
+	return NewFilter(field, "<=", value)
+}
+
+func Like(field string, pattern string) Expr {
+	return NewFilter(field, "LIKE", pattern)
+}
+
+func ILike(field string, pattern string) Expr {
+	return NewFilter(field, "ILIKE", pattern)
+}
+
+func In(field string, values ...any) Expr {
+	return NewFilter(field, "IN", values)
+}
+
+func NotIn(field string, values ...any) Expr {
+	return NewFilter(field, "NOT IN", values)
+}
+
+func IsNull(field string) Expr {
+	return NewFilter(field, "IS NULL", nil)
+}
+
+func IsNotNull(field string) Expr {
+	return NewFilter(field, "IS NOT NULL", nil)
+}
+
+// build compiles a single comparison, the leaf of every expression.
+func (f Filter) build(b *sqlBuilder) (string, error) {
+	operator := strings.Join(strings.Fields(strings.ToUpper(f.Operator)), " ")
+
+	switch operator {
+	case "EXPR":
+		expr, ok := f.Value.(Expr)
+		if !ok || expr == nil {
+			return "", &InvalidFilterError{Operator: operator, Reason: fmt.Sprintf("expected an expression, got %T", f.Value)}
+		}
+		return expr.build(b)
+
+	case "RAW":
+		// only NewUnsafeRawFilter can produce this value type
+		raw, ok := f.Value.(unsafeSQL)
+		if !ok {
+			return "", &InvalidFilterError{Operator: operator, Reason: "raw SQL requires NewUnsafeRawFilter"}
+		}
+		return "(" + string(raw) + ")", nil
+	}
+
+	field, err := b.column(f.Field, operator)
+	if err != nil {
+		return "", err
+	}
+
+	switch operator {
+	case "IN", "NOT IN":
+		values, err := sliceValues(f.Value)
+		if err != nil {
+			return "", &InvalidFilterError{Field: f.Field, Operator: operator, Reason: err.Error()}
+		}
+
+		// an empty list matches nothing, or everything when negated
+		if len(values) == 0 {
+			if operator == "IN" {
+				return "FALSE", nil
+			}
+			return "TRUE", nil
+		}
+
+		placeholders := make([]string, len(values))
+		for i, value := range values {
+			placeholders[i] = b.bind(value)
+		}
+		return fmt.Sprintf("%s %s (%s)", field, operator, strings.Join(placeholders, ", ")), nil
+
+	case "IS NULL", "IS NOT NULL":
+		return fmt.Sprintf("%s %s", field, operator), nil
+
+	case "@>", "?", "?|", "?&":
+		return buildJSONCondition(b, f, operator)
+
+	default:
+		if !comparisonOperators[operator] {
+			return "", &InvalidFilterError{Field: f.Field, Operator: f.Operator, Reason: "unsupported operator"}
+		}
+		if strings.HasSuffix(operator, "LIKE") {
+			return b.dialect.like(field, operator, b.bind(f.Value)), nil
+		}
+		return fmt.Sprintf("%s %s %s", field, operator, b.bind(f.Value)), nil
+	}
+}
+
+// sliceValues flattens any slice or array into a list of arguments.
+func sliceValues(value any) ([]any, error) {
+	if values, ok := value.([]any); ok {
+		return values, nil
+	}
+
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("expected a list of values, got %T", value)
+	}
+
+	values := make([]any, v.Len())
+	for i := range values {
+		values[i] = v.Index(i).Interface()
+	}
+	return values, nil
+}
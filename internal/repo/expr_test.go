@@ -0,0 +1,74 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/xligenda/ods-servers/internal/structs"
+)
+
+func TestBuildExpr(t *testing.T) {
+	r := NewRepository[string, structs.Invite](nil, "invites")
+	expires := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		expr Expr
+		sql  string
+		args []any
+	}{
+		{
+			name: "nested",
+			expr: And(Or(In("id", "a", "b"), ILike("id", "%c%")), Not(Eq("server", 2))),
+			sql:  `(("id" IN ($1, $2) OR "id" ILIKE $3) AND NOT ("server" = $4))`,
+			args: []any{"a", "b", "%c%", 2},
+		},
+		{name: "empty and", expr: And(), sql: "TRUE"},
+		{name: "empty or", expr: Or(), sql: "FALSE"},
+		{name: "empty in", expr: In("id"), sql: "FALSE"},
+		{name: "empty not in", expr: NotIn("id"), sql: "TRUE"},
+		{name: "null checks", expr: Or(IsNull("used_at"), IsNotNull("revoked_at")), sql: `("used_at" IS NULL OR "revoked_at" IS NOT NULL)`},
+		{name: "time", expr: Lte("expires_at", expires), sql: `"expires_at" <= $1`, args: []any{expires}},
+	}
+
+	for _, tt := range tests {
+		b := r.newSQLBuilder()
+		sql, err := tt.expr.build(b)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if sql != tt.sql {
+			t.Errorf("%s: sql = %s, want %s", tt.name, sql, tt.sql)
+		}
+		if fmt.Sprint(b.args) != fmt.Sprint(tt.args) {
+			t.Errorf("%s: args = %v, want %v", tt.name, b.args, tt.args)
+		}
+	}
+
+	for name, expr := range map[string]Expr{
+		"nil in and":      And(Eq("id", "a"), nil),
+		"nil in or":       Or(nil, Eq("id", "a")),
+		"nil not":         Not(nil),
+		"unknown field":   Not(Eq("missing", 1)),
+		"raw without api": NewFilter("", "RAW", "1 = 1"),
+	} {
+		if _, err := expr.build(r.newSQLBuilder()); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("%s: err = %v, want %v", name, err, ErrInvalidFilter)
+		}
+	}
+
+	// MemoryRepository rejects nil expressions the same way
+	m := NewMemoryRepository[string, structs.Invite]("invites")
+	for name, expr := range map[string]Expr{
+		"nil in and": And(nil),
+		"nil in or":  Or(nil),
+		"nil not":    Not(nil),
+	} {
+		if _, err := expr.eval(m.evalRow(&structs.Invite{})); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("%s: eval err = %v, want %v", name, err, ErrInvalidFilter)
+		}
+	}
+}
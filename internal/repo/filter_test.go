@@ -0,0 +1,133 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xligenda/ods-servers/internal/structs"
+)
+
+var filterBase = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// filterRepositories returns both implementations holding the same invites:
+//
+//	a  server 1  target 100  expires +0h  used +1h  created zero
+//	b  server 1  target 200  expires +1h            created base
+//	c  server 2  target 300  expires +2h  revoked   created zero
+//	d  server 2  target 400  expires +3h  used +2h  created base
+func filterRepositories(t *testing.T) map[string]Repository[string, structs.Invite] {
+	t.Helper()
+
+	db := openTestDB(t)
+	db.MustExec("INSERT INTO servers (id, guild) VALUES (1, 10), (2, 20)")
+	repositories := map[string]Repository[string, structs.Invite]{
+		"sql":    NewRepository[string, structs.Invite](db, "invites"),
+		"memory": NewMemoryRepository[string, structs.Invite]("invites"),
+	}
+
+	at := func(hours int) *time.Time {
+		t := filterBase.Add(time.Duration(hours) * time.Hour)
+		return &t
+	}
+	invites := []structs.Invite{
+		{Code: "a", Server: 1, Target: 100, ExpiresAt: filterBase, UsedAt: at(1)},
+		{Code: "b", Server: 1, Target: 200, ExpiresAt: at(1).In(time.FixedZone("UTC+3", 3*3600)), CreatedAt: filterBase},
+		{Code: "c", Server: 2, Target: 300, ExpiresAt: at(2).In(time.FixedZone("UTC-5", -5*3600)), RevokedAt: at(0)},
+		{Code: "d", Server: 2, Target: 400, ExpiresAt: *at(3), UsedAt: at(2), CreatedAt: filterBase},
+	}
+	for _, r := range repositories {
+		for _, invite := range invites {
+			if _, err := r.Create(context.Background(), invite); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return repositories
+}
+
+func TestFilters(t *testing.T) {
+	later := filterBase.Add(time.Hour).In(time.FixedZone("UTC+1", 3600))
+
+	tests := []struct {
+		name    string
+		filters []Filter
+		want    string
+		err     error
+	}{
+		{name: "none", want: "a b c d"},
+		{name: "equal", filters: []Filter{NewFilter("server", "=", 1)}, want: "a b"},
+		{name: "not equal", filters: []Filter{NewFilter("server", "<>", 1)}, want: "c d"},
+		{name: "greater", filters: []Filter{NewFilter("target", ">", 200)}, want: "c d"},
+		{name: "time in another zone", filters: []Filter{NewFilter("expires_at", ">=", later)}, want: "b c d"},
+		{name: "time before", filters: []Filter{NewFilter("expires_at", "<", later)}, want: "a"},
+		{name: "zero time", filters: []Filter{NewFilter("created_at", "=", time.Time{})}, want: "a c"},
+		{name: "after zero time", filters: []Filter{NewFilter("created_at", ">", time.Time{})}, want: "b d"},
+		{name: "like", filters: []Filter{NewFilter("id", "LIKE", "a%")}, want: "a"},
+		{name: "like is case sensitive", filters: []Filter{NewFilter("id", "LIKE", "A%")}, want: ""},
+		{name: "ilike", filters: []Filter{NewFilter("id", "ILIKE", "B")}, want: "b"},
+		{name: "not like", filters: []Filter{NewFilter("id", "NOT LIKE", "_")}, want: ""},
+		{name: "in", filters: []Filter{NewFilter("target", "IN", []int{100, 400, 500})}, want: "a d"},
+		{name: "empty in", filters: []Filter{NewFilter("target", "IN", []int{})}, want: ""},
+		{name: "empty not in", filters: []Filter{NewFilter("target", "NOT IN", []int{})}, want: "a b c d"},
+		{name: "is null", filters: []Filter{NewFilter("used_at", "IS NULL", nil)}, want: "b c"},
+		{name: "is not null", filters: []Filter{NewFilter("used_at", "IS NOT NULL", nil)}, want: "a d"},
+		// comparisons with NULL are unknown, so NOT does not match them either
+		{name: "not equal skips null", filters: []Filter{NewFilter("used_at", "<>", filterBase.Add(time.Hour))}, want: "d"},
+		{name: "negated null comparison", filters: []Filter{NewExprFilter(Not(Eq("used_at", filterBase.Add(time.Hour))))}, want: "d"},
+		{name: "filters are and-ed", filters: []Filter{NewFilter("server", "=", 2), NewFilter("used_at", "IS NULL", nil)}, want: "c"},
+		{name: "or", filters: []Filter{NewExprFilter(Or(Eq("id", "a"), IsNotNull("revoked_at")))}, want: "a c"},
+		{
+			name: "nested",
+			filters: []Filter{NewExprFilter(And(
+				Or(Eq("server", 1), Gt("target", 350)),
+				Not(In("id", "a", "z")),
+			))},
+			want: "b d",
+		},
+		{name: "or with unknown", filters: []Filter{NewExprFilter(Or(Lt("used_at", filterBase.Add(90*time.Minute)), Eq("server", 2)))}, want: "a c d"},
+		{name: "unknown field", filters: []Filter{NewFilter("missing", "=", 1)}, err: ErrInvalidFilter},
+		{name: "unknown field in expression", filters: []Filter{NewExprFilter(Or(Eq("id", "a"), Eq("missing", 1)))}, err: ErrInvalidFilter},
+		{name: "unsupported operator", filters: []Filter{NewFilter("id", "~", "a")}, err: ErrInvalidFilter},
+		{name: "in without a list", filters: []Filter{NewFilter("id", "IN", "a")}, err: ErrInvalidFilter},
+	}
+
+	for name, r := range filterRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, tt := range tests {
+				found, err := r.Find(context.Background(), tt.filters, nil)
+				if tt.err != nil {
+					if !errors.Is(err, tt.err) {
+						t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Errorf("%s: %v", tt.name, err)
+					continue
+				}
+
+				sort.Slice(found, func(i, j int) bool { return found[i].Code < found[j].Code })
+				if got := joinCodes(found); got != tt.want {
+					t.Errorf("%s: found %q, want %q", tt.name, got, tt.want)
+				}
+
+				count, err := r.Count(context.Background(), tt.filters, nil)
+				if err != nil || count != int64(len(found)) {
+					t.Errorf("%s: count = %d, %v, want %d", tt.name, count, err, len(found))
+				}
+			}
+		})
+	}
+}
+
+func joinCodes(invites []*structs.Invite) string {
+	codes := make([]string, len(invites))
+	for i, invite := range invites {
+		codes[i] = invite.Code
+	}
+	return strings.Join(codes, " ")
+}
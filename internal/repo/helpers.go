@@ -6,20 +6,41 @@ import (
 	"reflect"
 	"strings"
 	"time"
-
-	"github.com/lib/pq"
 )
 
-func (r *GenericRepository[I, T]) buildSelectQuery(filters []Filter, opts *QueryOptions) (string, []any) {
-	query := fmt.Sprintf("SELECT * FROM %s", pq.QuoteIdentifier(r.tableName))
+func (r *GenericRepository[I, T]) buildSelectQuery(filters []Filter, opts *QueryOptions) (string, []any, error) {
+	selectList := "*"
+	if opts != nil && len(opts.Columns) > 0 {
+		quoted := make([]string, len(opts.Columns))
+		for i, column := range opts.Columns {
+			if !r.hasColumn(column) {
+				return "", nil, &InvalidFilterError{Field: column, Reason: "unknown column"}
+			}
+			quoted[i] = r.dialect.quote(column)
+		}
+		selectList = strings.Join(quoted, ", ")
+	}
 
-	whereClause, args := r.buildWhereClause(filters)
+	query := fmt.Sprintf("SELECT %s FROM %s", selectList, r.dialect.quote(r.tableName))
+
+	whereClause, args, err := r.buildWhereClause(filters, scopeOf(opts))
+	if err != nil {
+		return "", nil, err
+	}
 	query += whereClause
 
 	if opts != nil {
-		if opts.OrderBy != "" {
-			query += fmt.Sprintf(" ORDER BY %s", pq.QuoteIdentifier(opts.OrderBy))
+		keys, err := opts.sortKeys()
+		if err != nil {
+			return "", nil, err
 		}
+
+		orderClause, err := r.buildOrderClause(keys)
+		if err != nil {
+			return "", nil, err
+		}
+		query += orderClause
+
 		if opts.Limit > 0 {
 			query += fmt.Sprintf(" LIMIT %d", opts.Limit)
 		}
@@ -28,101 +49,32 @@ func (r *GenericRepository[I, T]) buildSelectQuery(filters []Filter, opts *Query
 		}
 	}
 
-	return query, args
+	return query, args, nil
 }
 
-func (r *GenericRepository[I, T]) buildWhereClause(filters []Filter) (string, []any) {
-	if len(filters) == 0 {
-		return "", nil
-	}
-
-	var conditions []string
-	var args []any
-	argIndex := 1
+// buildWhereClause joins the filters and the soft delete scope with AND,
+// placeholders continue after the given args.
+func (r *GenericRepository[I, T]) buildWhereClause(filters []Filter, scope DeletedScope, args ...any) (string, []any, error) {
+	b := r.newSQLBuilder(args...)
 
+	conditions := make([]string, 0, len(filters)+1)
 	for _, filter := range filters {
-		condition, arg := r.buildCondition(filter, argIndex)
-		conditions = append(conditions, condition)
-
-		if arg != nil {
-			switch v := arg.(type) {
-			case []any:
-				args = append(args, v...)
-				argIndex += len(v)
-			default:
-				args = append(args, v)
-				argIndex++
-			}
+		condition, err := filter.build(b)
+		if err != nil {
+			return "", nil, err
 		}
+		conditions = append(conditions, condition)
 	}
 
-	whereClause := " WHERE " + strings.Join(conditions, " AND ")
-	return whereClause, args
-}
-
-func (r *GenericRepository[I, T]) buildCondition(filter Filter, argIndex int) (string, any) {
-	field := pq.QuoteIdentifier(filter.Field)
-
-	switch strings.ToUpper(filter.Operator) {
-	case "OR":
-		if orData, ok := filter.Value.(map[string]any); ok {
-			if conditions, exists := orData["conditions"].([]map[string]any); exists {
-				var orParts []string
-				var orArgs []any
-				currentArgIndex := argIndex
-
-				for _, condition := range conditions {
-					fieldName, _ := condition["field"].(string)
-					operator, _ := condition["operator"].(string)
-					value := condition["value"]
-
-					quotedField := pq.QuoteIdentifier(fieldName)
-					orParts = append(orParts, fmt.Sprintf("%s %s $%d", quotedField, operator, currentArgIndex))
-					orArgs = append(orArgs, value)
-					currentArgIndex++
-				}
-
-				condition := fmt.Sprintf("(%s)", strings.Join(orParts, " OR "))
-				return condition, orArgs
-			}
-		}
-
-	case "RAW":
-		if rawSQL, ok := filter.Value.(string); ok {
-			return rawSQL, nil
-		}
-
-	case "IN":
-		if values, ok := filter.Value.([]any); ok {
-			placeholders := make([]string, len(values))
-			for i := range values {
-				placeholders[i] = fmt.Sprintf("$%d", argIndex+i)
-			}
-			condition := fmt.Sprintf("%s IN (%s)", field, strings.Join(placeholders, ", "))
-			return condition, values
-		}
-
-	case "NOT IN":
-		if values, ok := filter.Value.([]any); ok {
-			placeholders := make([]string, len(values))
-			for i := range values {
-				placeholders[i] = fmt.Sprintf("$%d", argIndex+i)
-			}
-			condition := fmt.Sprintf("%s NOT IN (%s)", field, strings.Join(placeholders, ", "))
-			return condition, values
-		}
-
-	case "LIKE", "ILIKE":
-		condition := fmt.Sprintf("%s %s $%d", field, filter.Operator, argIndex)
-		return condition, filter.Value
-
-	default:
-		condition := fmt.Sprintf("%s %s $%d", field, filter.Operator, argIndex)
-		return condition, filter.Value
+	if condition := r.scopeCondition(scope); condition != "" {
+		conditions = append(conditions, condition)
+	}
+	if len(conditions) == 0 {
+		return "", b.args, nil
 	}
 
-	condition := fmt.Sprintf("%s = $%d", field, argIndex)
-	return condition, filter.Value
+	whereClause := " WHERE " + strings.Join(conditions, " AND ")
+	return whereClause, b.args, nil
 }
 
 func (r *GenericRepository[I, T]) buildInsertData(entity T) ([]string, []any, []string) {
@@ -157,9 +109,9 @@ func (r *GenericRepository[I, T]) buildInsertData(entity T) ([]string, []any, []
 			continue
 		}
 
-		fields = append(fields, pq.QuoteIdentifier(fieldName))
+		fields = append(fields, r.dialect.quote(fieldName))
 		values = append(values, fieldValue)
-		placeholders = append(placeholders, fmt.Sprintf("$%d", len(values)))
+		placeholders = append(placeholders, r.dialect.placeholder(len(values)))
 	}
 
 	return fields, values, placeholders
@@ -190,13 +142,18 @@ func (r *GenericRepository[I, T]) buildUpdateData(entity T) ([]string, []any) {
 			continue
 		}
 
+		// the version is only ever incremented by the database
+		if fieldName == versionColumn && r.versioned {
+			continue
+		}
+
 		fieldValue := r.getFieldValue(value)
 
 		if value.Kind() == reflect.Ptr && value.IsNil() {
 			continue
 		}
 
-		fields = append(fields, fmt.Sprintf("%s = $%d", pq.QuoteIdentifier(fieldName), len(values)+1))
+		fields = append(fields, fmt.Sprintf("%s = %s", r.dialect.quote(fieldName), r.dialect.placeholder(len(values)+1)))
 		values = append(values, fieldValue)
 	}
 
@@ -215,22 +172,13 @@ func (r *GenericRepository[I, T]) getFieldValue(value reflect.Value) any {
 		return r.getFieldValue(value.Elem())
 	}
 
-	switch value.Type() {
-	case reflect.TypeOf(time.Time{}):
-		return value.Interface().(time.Time)
-	case reflect.TypeOf((*time.Time)(nil)).Elem():
-		if value.IsZero() {
-			return nil
-		}
-		return value.Interface().(time.Time)
+	// a zero time is bound as a value, only nil pointers are NULL
+	if value.Type() == reflect.TypeOf(time.Time{}) {
+		return r.dialect.bindValue(value.Interface())
 	}
 
 	switch value.Kind() {
 	case reflect.Slice, reflect.Map, reflect.Struct:
-		if value.Type() == reflect.TypeOf(time.Time{}) {
-			return value.Interface()
-		}
-
 		jsonData, err := json.Marshal(value.Interface())
 		if err != nil {
 			return nil
@@ -249,33 +197,42 @@ func NewFilter(field, operator string, value any) Filter {
 	}
 }
 
+// NewORFilter matches when any of the conditions matches.
 func NewORFilter(conditions ...Filter) Filter {
-	conditionMaps := make([]map[string]any, len(conditions))
+	exprs := make([]Expr, len(conditions))
 	for i, condition := range conditions {
-		conditionMaps[i] = map[string]any{
-			"field":    condition.Field,
-			"operator": condition.Operator,
-			"value":    condition.Value,
-		}
+		exprs[i] = condition
 	}
+	return NewExprFilter(Or(exprs...))
+}
 
+// NewExprFilter wraps an expression tree so it can be passed along plain filters.
+func NewExprFilter(expr Expr) Filter {
 	return Filter{
-		Field:    "custom_or",
-		Operator: "OR",
-		Value: map[string]any{
-			"conditions": conditionMaps,
-		},
+		Field:    "expr",
+		Operator: "EXPR",
+		Value:    expr,
 	}
 }
 
-func NewRawFilter(sql string) Filter {
+type unsafeSQL string
+
+// NewUnsafeRawFilter injects the SQL condition verbatim, it must never contain user input.
+func NewUnsafeRawFilter(sql string) Filter {
 	return Filter{
 		Field:    "raw_condition",
 		Operator: "RAW",
-		Value:    sql,
+		Value:    unsafeSQL(sql),
 	}
 }
 
+// NewRawFilter injects the SQL condition verbatim.
+//
+// Deprecated: use NewUnsafeRawFilter, the name says what it does.
+func NewRawFilter(sql string) Filter {
+	return NewUnsafeRawFilter(sql)
+}
+
 func NewQueryOptions() *QueryOptions {
 	return &QueryOptions{}
 }
@@ -285,6 +242,17 @@ func (opts *QueryOptions) WithOrderBy(orderBy string) *QueryOptions {
 	return opts
 }
 
+func (opts *QueryOptions) WithSort(keys ...SortKey) *QueryOptions {
+	opts.Sort = append(opts.Sort, keys...)
+	return opts
+}
+
+// WithColumns selects only the given columns, the other fields of the result stay zero.
+func (opts *QueryOptions) WithColumns(columns ...string) *QueryOptions {
+	opts.Columns = append(opts.Columns, columns...)
+	return opts
+}
+
 func (opts *QueryOptions) WithLimit(limit int) *QueryOptions {
 	opts.Limit = limit
 	return opts
@@ -0,0 +1,335 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// QueryEvent describes a statement executed by the repository.
+type QueryEvent struct {
+	Table string
+	// repository method, e.g. Find or Upsert
+	Operation string
+	Query     string
+	// redacted by the repository's Redactor
+	Args     []any
+	Duration time.Duration
+	// rows returned or affected, -1 when unknown, e.g. for streamed rows
+	Rows int64
+	Err  error
+	InTx bool
+}
+
+// Hook observes every statement of a repository, including those running
+// in a transaction. Hooks are called synchronously after the statement.
+type Hook interface {
+	AfterQuery(ctx context.Context, event QueryEvent)
+}
+
+type HookFunc func(ctx context.Context, event QueryEvent)
+
+func (f HookFunc) AfterQuery(ctx context.Context, event QueryEvent) {
+	f(ctx, event)
+}
+
+// Redactor replaces an argument before hooks see it.
+type Redactor func(arg any) any
+
+// WithHooks adds hooks observing the statements of the repository.
+func WithHooks(hooks ...Hook) Option {
+	return func(o *options) {
+		o.hooks = append(o.hooks, hooks...)
+	}
+}
+
+// WithRedactor replaces RedactArgs, e.g. with KeepArgs during development.
+func WithRedactor(redact Redactor) Option {
+	return func(o *options) {
+		o.redact = redact
+	}
+}
+
+// RedactArgs keeps NULLs, plain numbers, booleans and times, which rarely
+// carry personal data, and hides strings and bytes behind their length.
+// Numbers of named types, e.g. Discord IDs (structs.Snowflake), identify
+// people and are replaced by their type.
+func RedactArgs(arg any) any {
+	switch value := arg.(type) {
+	case nil, bool, time.Time:
+		return value
+	case string:
+		return fmt.Sprintf("<redacted %d chars>", len(value))
+	case []byte:
+		return fmt.Sprintf("<redacted %d bytes>", len(value))
+	}
+
+	v := reflect.ValueOf(arg)
+	if (v.CanInt() || v.CanUint() || v.CanFloat()) && v.Type().PkgPath() == "" {
+		return arg
+	}
+	return fmt.Sprintf("<redacted %T>", arg)
+}
+
+func KeepArgs(arg any) any {
+	return arg
+}
+
+// hookedQuerier reports the statements of a connection or transaction to the hooks.
+type hookedQuerier struct {
+	querier
+	table     string
+	operation string
+	inTx      bool
+	hooks     []Hook
+	redact    Redactor
+}
+
+func (r *GenericRepository[I, T]) hooked(q querier, operation string, inTx bool) querier {
+	if len(r.options.hooks) == 0 {
+		return q
+	}
+
+	redact := r.options.redact
+	if redact == nil {
+		redact = RedactArgs
+	}
+	return &hookedQuerier{querier: q, table: r.tableName, operation: operation, inTx: inTx, hooks: r.options.hooks, redact: redact}
+}
+
+func (q *hookedQuerier) report(ctx context.Context, start time.Time, query string, args []any, rows int64, err error) {
+	redacted := make([]any, len(args))
+	for i, arg := range args {
+		redacted[i] = q.redact(arg)
+	}
+
+	event := QueryEvent{
+		Table:     q.table,
+		Operation: q.operation,
+		Query:     query,
+		Args:      redacted,
+		Duration:  time.Since(start),
+		Rows:      rows,
+		Err:       err,
+		InTx:      q.inTx,
+	}
+	for _, hook := range q.hooks {
+		hook.AfterQuery(ctx, event)
+	}
+}
+
+func (q *hookedQuerier) GetContext(ctx context.Context, dest any, query string, args ...any) error {
+	start := time.Now()
+	err := q.querier.GetContext(ctx, dest, query, args...)
+
+	var rows int64 = 1
+	if err != nil {
+		rows = 0
+	}
+	q.report(ctx, start, query, args, rows, err)
+	return err
+}
+
+func (q *hookedQuerier) SelectContext(ctx context.Context, dest any, query string, args ...any) error {
+	start := time.Now()
+	err := q.querier.SelectContext(ctx, dest, query, args...)
+
+	var rows int64
+	if v := reflect.Indirect(reflect.ValueOf(dest)); v.Kind() == reflect.Slice {
+		rows = int64(v.Len())
+	}
+	q.report(ctx, start, query, args, rows, err)
+	return err
+}
+
+func (q *hookedQuerier) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	start := time.Now()
+	result, err := q.querier.ExecContext(ctx, query, args...)
+
+	var rows int64 = -1
+	if err == nil {
+		if affected, affectedErr := result.RowsAffected(); affectedErr == nil {
+			rows = affected
+		}
+	}
+	q.report(ctx, start, query, args, rows, err)
+	return result, err
+}
+
+func (q *hookedQuerier) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := q.querier.QueryContext(ctx, query, args...)
+	q.report(ctx, start, query, args, -1, err)
+	return rows, err
+}
+
+func (q *hookedQuerier) QueryxContext(ctx context.Context, query string, args ...any) (*sqlx.Rows, error) {
+	start := time.Now()
+	rows, err := q.querier.QueryxContext(ctx, query, args...)
+	q.report(ctx, start, query, args, -1, err)
+	return rows, err
+}
+
+// QueryRowxContext reports without an error, it surfaces on Scan.
+func (q *hookedQuerier) QueryRowxContext(ctx context.Context, query string, args ...any) *sqlx.Row {
+	start := time.Now()
+	row := q.querier.QueryRowxContext(ctx, query, args...)
+	q.report(ctx, start, query, args, -1, row.Err())
+	return row
+}
+
+func (q *hookedQuerier) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	start := time.Now()
+	row := q.querier.QueryRowContext(ctx, query, args...)
+	q.report(ctx, start, query, args, -1, row.Err())
+	return row
+}
+
+// NewSlogHook logs failed statements as errors, statements slower than
+// slowThreshold as warnings and all others at debug level. A zero
+// threshold disables slow query warnings.
+func NewSlogHook(logger *slog.Logger, slowThreshold time.Duration) Hook {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return HookFunc(func(ctx context.Context, event QueryEvent) {
+		attrs := []slog.Attr{
+			slog.String("table", event.Table),
+			slog.String("operation", event.Operation),
+			slog.String("query", event.Query),
+			slog.Any("args", event.Args),
+			slog.Duration("duration", event.Duration),
+			slog.Int64("rows", event.Rows),
+			slog.Bool("tx", event.InTx),
+		}
+
+		switch {
+		// a missing row is an answer, not a failure
+		case event.Err != nil && !errors.Is(event.Err, sql.ErrNoRows):
+			logger.LogAttrs(ctx, slog.LevelError, "query failed", append(attrs, slog.Any("error", event.Err))...)
+		case slowThreshold > 0 && event.Duration >= slowThreshold:
+			logger.LogAttrs(ctx, slog.LevelWarn, "slow query", attrs...)
+		default:
+			logger.LogAttrs(ctx, slog.LevelDebug, "query", attrs...)
+		}
+	})
+}
+
+var DefaultQueryBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+}
+
+// QueryHistogram is the latency distribution of one operation on one table.
+type QueryHistogram struct {
+	Table     string `json:"table"`
+	Operation string `json:"operation"`
+	// cumulative counts of the statements at most as slow as the bucket of the same index
+	Counts []uint64      `json:"counts"`
+	Count  uint64        `json:"count"`
+	Sum    time.Duration `json:"sum"`
+	Errors uint64        `json:"errors"`
+}
+
+type histogramKey struct {
+	table     string
+	operation string
+}
+
+// MetricsHook records latency histograms per table and operation. It is an
+// expvar.Var, so expvar.Publish("queries", hook) exposes it on /debug/vars.
+type MetricsHook struct {
+	buckets    []time.Duration
+	mu         sync.Mutex
+	histograms map[histogramKey]*QueryHistogram
+}
+
+// NewMetricsHook creates the hook, DefaultQueryBuckets are used without buckets.
+func NewMetricsHook(buckets ...time.Duration) *MetricsHook {
+	if len(buckets) == 0 {
+		buckets = DefaultQueryBuckets
+	}
+	buckets = append([]time.Duration(nil), buckets...)
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+
+	return &MetricsHook{buckets: buckets, histograms: make(map[histogramKey]*QueryHistogram)}
+}
+
+func (h *MetricsHook) AfterQuery(ctx context.Context, event QueryEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := histogramKey{table: event.Table, operation: event.Operation}
+	histogram, ok := h.histograms[key]
+	if !ok {
+		histogram = &QueryHistogram{Table: event.Table, Operation: event.Operation, Counts: make([]uint64, len(h.buckets))}
+		h.histograms[key] = histogram
+	}
+
+	for i, bound := range h.buckets {
+		if event.Duration <= bound {
+			histogram.Counts[i]++
+		}
+	}
+	histogram.Count++
+	histogram.Sum += event.Duration
+	if event.Err != nil && !errors.Is(event.Err, sql.ErrNoRows) {
+		histogram.Errors++
+	}
+}
+
+func (h *MetricsHook) Buckets() []time.Duration {
+	return append([]time.Duration(nil), h.buckets...)
+}
+
+// Histograms returns a copy of the histograms ordered by table and operation.
+func (h *MetricsHook) Histograms() []QueryHistogram {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	histograms := make([]QueryHistogram, 0, len(h.histograms))
+	for _, histogram := range h.histograms {
+		copied := *histogram
+		copied.Counts = append([]uint64(nil), histogram.Counts...)
+		histograms = append(histograms, copied)
+	}
+	sort.Slice(histograms, func(i, j int) bool {
+		if histograms[i].Table != histograms[j].Table {
+			return histograms[i].Table < histograms[j].Table
+		}
+		return histograms[i].Operation < histograms[j].Operation
+	})
+
+	return histograms
+}
+
+// String encodes the buckets and histograms as JSON, as expvar.Var requires.
+func (h *MetricsHook) String() string {
+	encoded, err := json.Marshal(struct {
+		Buckets    []time.Duration  `json:"buckets"`
+		Histograms []QueryHistogram `json:"histograms"`
+	}{h.Buckets(), h.Histograms()})
+	if err != nil {
+		return "{}"
+	}
+	return string(encoded)
+}
@@ -0,0 +1,36 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xligenda/ods-servers/internal/structs"
+)
+
+func TestRedactArgs(t *testing.T) {
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		arg  any
+		want any
+	}{
+		{nil, nil},
+		{true, true},
+		{42, 42},
+		{int64(42), int64(42)},
+		{1.5, 1.5},
+		{at, at},
+		{"alice", "<redacted 5 chars>"},
+		{[]byte("{}"), "<redacted 2 bytes>"},
+		{structs.Snowflake(80351110224678912), "<redacted structs.Snowflake>"},
+		{structs.DiscordID(80351110224678912), "<redacted structs.Snowflake>"},
+		{structs.ServerTag(3), 3},
+		{[]string{"a"}, "<redacted []string>"},
+	}
+
+	for _, tt := range tests {
+		if got := RedactArgs(tt.arg); got != tt.want {
+			t.Errorf("RedactArgs(%#v) = %#v, want %#v", tt.arg, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package repo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// jsonOperators query inside the JSONB columns getFieldValue stores maps, slices and structs in.
+var jsonOperators = map[string]bool{"@>": true, "?": true, "?|": true, "?&": true}
+
+// entityJSONColumns lists the columns stored as JSON.
+func entityJSONColumns(t reflect.Type, columns map[string]int) map[string]bool {
+	jsonColumns := make(map[string]bool)
+	for column, index := range columns {
+		if isJSONType(t.Field(index).Type) {
+			jsonColumns[column] = true
+		}
+	}
+	return jsonColumns
+}
+
+// isJSONType reports whether getFieldValue stores the type as JSON.
+func isJSONType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Map:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() != reflect.Uint8
+	case reflect.Struct:
+		return t != reflect.TypeOf(time.Time{})
+	default:
+		return false
+	}
+}
+
+// Contains matches JSON columns containing the value, e.g. Contains("roles", map[string]string{"1234": "Администратор"}).
+func Contains(field string, value any) Expr {
+	return NewFilter(field, "@>", value)
+}
+
+// HasKey matches JSON objects with the top-level key, or arrays with the string element.
+func HasKey(field string, key string) Expr {
+	return NewFilter(field, "?", key)
+}
+
+func HasAnyKey(field string, keys ...string) Expr {
+	return NewFilter(field, "?|", keys)
+}
+
+func HasAllKeys(field string, keys ...string) Expr {
+	return NewFilter(field, "?&", keys)
+}
+
+type jsonPathExpr struct {
+	field    string
+	path     []string
+	operator string
+	value    any
+}
+
+// PathCompare compares the value extracted at the path, e.g.
+// PathCompare("servers", []string{"5", "0"}, "=", "Администратор"). Numbers and
+// booleans are compared as such, anything else as text.
+func PathCompare(field string, path []string, operator string, value any) Expr {
+	return jsonPathExpr{field: field, path: path, operator: operator, value: value}
+}
+
+type jsonPathExistsExpr struct {
+	field string
+	path  string
+	vars  map[string]any
+}
+
+// PathExists matches rows where the SQL/JSON path query returns any item, e.g.
+// users holding a role on any server:
+//
+//	PathExists("servers", "$.*[*] ? (@ == $role)", map[string]any{"role": "Администратор"})
+func PathExists(field string, path string, vars map[string]any) Expr {
+	return jsonPathExistsExpr{field: field, path: path, vars: vars}
+}
+
+func (b *sqlBuilder) jsonColumn(field, operator string) (string, error) {
+	quoted, err := b.column(field, operator)
+	if err != nil {
+		return "", err
+	}
+	if !b.jsonColumns[field] {
+		return "", &InvalidFilterError{Field: field, Operator: operator, Reason: "not a JSON column"}
+	}
+	if !b.dialect.jsonb() {
+		return "", fmt.Errorf("%s on %s: %w", operator, b.dialect.Name(), errors.ErrUnsupported)
+	}
+	return quoted, nil
+}
+
+func buildJSONCondition(b *sqlBuilder, f Filter, operator string) (string, error) {
+	field, err := b.jsonColumn(f.Field, operator)
+	if err != nil {
+		return "", err
+	}
+
+	switch operator {
+	case "@>":
+		document, err := json.Marshal(f.Value)
+		if err != nil {
+			return "", &InvalidFilterError{Field: f.Field, Operator: operator, Reason: err.Error()}
+		}
+		return fmt.Sprintf("%s @> %s::jsonb", field, b.bind(string(document))), nil
+
+	case "?":
+		key, ok := f.Value.(string)
+		if !ok {
+			return "", &InvalidFilterError{Field: f.Field, Operator: operator, Reason: "expected a string key"}
+		}
+		return fmt.Sprintf("%s ? %s", field, b.bind(key)), nil
+
+	default:
+		values, err := sliceValues(f.Value)
+		if err != nil {
+			return "", &InvalidFilterError{Field: f.Field, Operator: operator, Reason: err.Error()}
+		}
+
+		keys := make([]string, len(values))
+		for i, value := range values {
+			keys[i] = fmt.Sprint(value)
+		}
+		return fmt.Sprintf("%s %s %s::text[]", field, operator, b.bind(pq.Array(keys))), nil
+	}
+}
+
+func (e jsonPathExpr) build(b *sqlBuilder) (string, error) {
+	field, err := b.jsonColumn(e.field, e.operator)
+	if err != nil {
+		return "", err
+	}
+	if !comparisonOperators[e.operator] {
+		return "", &InvalidFilterError{Field: e.field, Operator: e.operator, Reason: "unsupported operator"}
+	}
+
+	extracted := fmt.Sprintf("(%s #>> %s::text[])", field, b.bind(pq.Array(e.path)))
+	switch reflect.ValueOf(e.value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		extracted += "::numeric"
+	case reflect.Bool:
+		extracted += "::boolean"
+	}
+
+	return fmt.Sprintf("%s %s %s", extracted, e.operator, b.bind(e.value)), nil
+}
+
+func (e jsonPathExistsExpr) build(b *sqlBuilder) (string, error) {
+	field, err := b.jsonColumn(e.field, "jsonb_path_exists")
+	if err != nil {
+		return "", err
+	}
+
+	vars := e.vars
+	if vars == nil {
+		vars = map[string]any{}
+	}
+	encoded, err := json.Marshal(vars)
+	if err != nil {
+		return "", &InvalidFilterError{Field: e.field, Reason: err.Error()}
+	}
+
+	return fmt.Sprintf("jsonb_path_exists(%s, %s::jsonpath, %s::jsonb)", field, b.bind(e.path), b.bind(string(encoded))), nil
+}
@@ -0,0 +1,122 @@
+package repo
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/xligenda/ods-servers/internal/structs"
+)
+
+func TestBuildJSONExpr(t *testing.T) {
+	r := NewRepository[string, structs.User](nil, "users", WithDialect(Postgres))
+
+	tests := []struct {
+		name string
+		expr Expr
+		sql  string
+		args []any
+	}{
+		{
+			name: "contains",
+			expr: Contains("servers", map[string][]string{"5": {"admin"}}),
+			sql:  `"servers" @> $1::jsonb`,
+			args: []any{`{"5":["admin"]}`},
+		},
+		{
+			name: "has key",
+			expr: HasKey("servers", "5"),
+			sql:  `"servers" ? $1`,
+			args: []any{"5"},
+		},
+		{
+			name: "has any key",
+			expr: HasAnyKey("servers", "5", "6"),
+			sql:  `"servers" ?| $1::text[]`,
+			args: []any{pq.Array([]string{"5", "6"})},
+		},
+		{
+			name: "has all keys",
+			expr: HasAllKeys("servers", "5", "6"),
+			sql:  `"servers" ?& $1::text[]`,
+			args: []any{pq.Array([]string{"5", "6"})},
+		},
+		{
+			name: "path compare text",
+			expr: PathCompare("servers", []string{"5", "0"}, "=", "admin"),
+			sql:  `("servers" #>> $1::text[]) = $2`,
+			args: []any{pq.Array([]string{"5", "0"}), "admin"},
+		},
+		{
+			name: "path compare number",
+			expr: PathCompare("servers", []string{"5", "level"}, ">=", 3),
+			sql:  `("servers" #>> $1::text[])::numeric >= $2`,
+			args: []any{pq.Array([]string{"5", "level"}), 3},
+		},
+		{
+			name: "path compare bool",
+			expr: PathCompare("servers", []string{"5", "active"}, "=", true),
+			sql:  `("servers" #>> $1::text[])::boolean = $2`,
+			args: []any{pq.Array([]string{"5", "active"}), true},
+		},
+		{
+			name: "path exists",
+			expr: PathExists("servers", "$.*[*] ? (@ == $role)", map[string]any{"role": "admin"}),
+			sql:  `jsonb_path_exists("servers", $1::jsonpath, $2::jsonb)`,
+			args: []any{"$.*[*] ? (@ == $role)", `{"role":"admin"}`},
+		},
+		{
+			name: "path exists without vars",
+			expr: PathExists("servers", "$.*", nil),
+			sql:  `jsonb_path_exists("servers", $1::jsonpath, $2::jsonb)`,
+			args: []any{"$.*", `{}`},
+		},
+	}
+
+	for _, tt := range tests {
+		b := r.newSQLBuilder()
+		sql, err := tt.expr.build(b)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if sql != tt.sql {
+			t.Errorf("%s: sql = %s, want %s", tt.name, sql, tt.sql)
+		}
+		if !reflect.DeepEqual(b.args, tt.args) {
+			t.Errorf("%s: args = %v, want %v", tt.name, b.args, tt.args)
+		}
+	}
+
+	for name, expr := range map[string]Expr{
+		"not a JSON column":     HasKey("id", "5"),
+		"unknown field":         HasKey("missing", "5"),
+		"non-string key":        NewFilter("servers", "?", 5),
+		"keys not a slice":      NewFilter("servers", "?|", "5"),
+		"unsupported operator":  PathCompare("servers", []string{"5"}, "LIKE '%' OR", "x"),
+		"unencodable contains":  Contains("servers", func() {}),
+		"unencodable path vars": PathExists("servers", "$", map[string]any{"f": func() {}}),
+	} {
+		if _, err := expr.build(r.newSQLBuilder()); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("%s: err = %v, want %v", name, err, ErrInvalidFilter)
+		}
+	}
+}
+
+func TestJSONExprUnsupportedOnSQLite(t *testing.T) {
+	r := NewRepository[string, structs.User](nil, "users", WithDialect(SQLite))
+
+	for name, expr := range map[string]Expr{
+		"contains":     Contains("servers", map[string][]string{"5": {"admin"}}),
+		"has key":      HasKey("servers", "5"),
+		"has any key":  HasAnyKey("servers", "5"),
+		"has all keys": HasAllKeys("servers", "5"),
+		"path compare": PathCompare("servers", []string{"5", "0"}, "=", "admin"),
+		"path exists":  PathExists("servers", "$.*", nil),
+	} {
+		if _, err := expr.build(r.newSQLBuilder()); !errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("%s: err = %v, want %v", name, err, errors.ErrUnsupported)
+		}
+	}
+}
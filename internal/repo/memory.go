@@ -0,0 +1,739 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"reflect"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryRepository keeps the entities in memory, so code depending on a
+// Repository can be tested without a database. Filters and options are
+// validated exactly like the SQL repository and evaluated with the same
+// semantics, NULL handling included. Raw SQL and jsonpath filters return
+// errors.ErrUnsupported, and there are no column defaults or constraints
+// besides the primary key. Like a query, every method fails with the
+// context's error once it is done.
+type MemoryRepository[I IDsConstraint, T StructsConstraint[I]] struct {
+	// the SQL repository without a database, it validates filters and
+	// describes the columns of T
+	schema *GenericRepository[I, T]
+	mu     sync.RWMutex
+	rows   map[string]*T
+	// ids in insertion order, the order of unsorted results
+	order []string
+}
+
+func NewMemoryRepository[I IDsConstraint, T StructsConstraint[I]](tableName string, opts ...Option) *MemoryRepository[I, T] {
+	return &MemoryRepository[I, T]{
+		schema: NewRepository[I, T](nil, tableName, opts...),
+		rows:   make(map[string]*T),
+	}
+}
+
+func (m *MemoryRepository[I, T]) Find(ctx context.Context, filters []Filter, opts *QueryOptions) ([]*T, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, _, err := m.schema.buildSelectQuery(filters, opts); err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		opts = &QueryOptions{}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rows, err := m.match(filters, opts.Deleted)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := opts.sortKeys()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.sort(rows, keys); err != nil {
+		return nil, err
+	}
+
+	rows = rows[min(max(opts.Offset, 0), len(rows)):]
+	if opts.Limit > 0 && opts.Limit < len(rows) {
+		rows = rows[:opts.Limit]
+	}
+
+	result := make([]*T, len(rows))
+	for i, row := range rows {
+		result[i] = m.project(row, opts.Columns)
+	}
+
+	return result, nil
+}
+
+func (m *MemoryRepository[I, T]) FindOne(ctx context.Context, filters []Filter) (*T, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	entities, err := m.Find(ctx, filters, &QueryOptions{Limit: 1})
+	if err != nil || len(entities) == 0 {
+		return nil, err
+	}
+	return entities[0], nil
+}
+
+func (m *MemoryRepository[I, T]) FindByID(ctx context.Context, id string) (*T, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	filters := []Filter{{Field: "id", Operator: "=", Value: id}}
+	return m.FindOne(ctx, filters)
+}
+
+func (m *MemoryRepository[I, T]) Create(ctx context.Context, entity T) (*T, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := fmt.Sprint(entity.GetID())
+	if _, ok := m.rows[id]; ok {
+		return nil, fmt.Errorf("failed to create entity with id %s: %w", id, ErrConflict)
+	}
+
+	return m.insert(id, entity), nil
+}
+
+// CreateMany inserts all entities or none of them. With IgnoreConflicts the
+// entities whose id is taken are skipped and not returned.
+func (m *MemoryRepository[I, T]) CreateMany(ctx context.Context, entities []T, opts *BatchOptions) ([]*T, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := make([]string, 0, len(entities))
+	accepted := make([]T, 0, len(entities))
+	seen := make(map[string]bool, len(entities))
+	for _, entity := range entities {
+		id := fmt.Sprint(entity.GetID())
+		if _, ok := m.rows[id]; ok || seen[id] {
+			if opts != nil && opts.IgnoreConflicts {
+				continue
+			}
+			return nil, fmt.Errorf("failed to insert entities, id %s: %w", id, ErrConflict)
+		}
+		seen[id] = true
+		ids = append(ids, id)
+		accepted = append(accepted, entity)
+	}
+
+	created := make([]*T, len(accepted))
+	for i, entity := range accepted {
+		created[i] = m.insert(ids[i], entity)
+	}
+
+	return created, nil
+}
+
+// Update writes the entity. With a version column the write only succeeds
+// when the stored version equals the entity's, and increments it.
+func (m *MemoryRepository[I, T]) Update(ctx context.Context, id string, entity T) (*T, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if fields, _ := m.schema.buildUpdateData(entity); len(fields) == 0 {
+		return nil, fmt.Errorf("%w: no fields to update", ErrInvalidPatch)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	row, err := m.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, fmt.Errorf("entity with id %s: %w", id, ErrNotFound)
+	}
+	if m.schema.versioned && m.schema.entityVersion(*row) != m.schema.entityVersion(entity) {
+		return nil, &VersionConflictError{ID: id, Expected: m.schema.entityVersion(entity)}
+	}
+
+	m.assign(row, entity)
+	return m.clone(row), nil
+}
+
+func (m *MemoryRepository[I, T]) Upsert(ctx context.Context, entity T, conflictColumns []string) (*T, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(conflictColumns) == 0 {
+		conflictColumns = []string{"id"}
+	}
+	for _, column := range conflictColumns {
+		if !m.schema.hasColumn(column) {
+			return nil, &InvalidFilterError{Field: column, Reason: "unknown conflict column"}
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// conflicts include soft-deleted rows, like a unique index does
+	var existing *T
+	candidate := m.evalRow(&entity)
+	for _, id := range m.order {
+		conflict, err := andExpr(m.conflictExprs(candidate, conflictColumns)).eval(m.evalRow(m.rows[id]))
+		if err != nil {
+			return nil, err
+		}
+		if conflict == triTrue {
+			existing = m.rows[id]
+			break
+		}
+	}
+
+	if existing == nil {
+		id := fmt.Sprint(entity.GetID())
+		if _, ok := m.rows[id]; ok {
+			return nil, fmt.Errorf("failed to upsert entity with id %s: %w", id, ErrConflict)
+		}
+		return m.insert(id, entity), nil
+	}
+
+	if m.schema.versioned && m.schema.entityVersion(*existing) != m.schema.entityVersion(entity) {
+		return nil, &VersionConflictError{ID: fmt.Sprint(entity.GetID()), Expected: m.schema.entityVersion(entity)}
+	}
+
+	m.assign(existing, entity)
+	return m.clone(existing), nil
+}
+
+// Patch updates only the given columns, a nil value sets the column to NULL.
+// With a version column a "version" field is the expected version rather
+// than a new value.
+func (m *MemoryRepository[I, T]) Patch(ctx context.Context, id string, fields map[string]any) (*T, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.patch(id, fields)
+}
+
+// MergePatch applies a JSON Merge Patch (RFC 7386) addressed by the json
+// names of T, see GenericRepository.MergePatch.
+func (m *MemoryRepository[I, T]) MergePatch(ctx context.Context, id string, patch []byte) (*T, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &members); err != nil || members == nil {
+		return nil, fmt.Errorf("%w: merge patch must be a JSON object", ErrInvalidPatch)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	row, err := m.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, fmt.Errorf("entity with id %s: %w", id, ErrNotFound)
+	}
+
+	fields, err := m.schema.mergeFields(reflect.ValueOf(row).Elem(), members)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return m.clone(row), nil
+	}
+
+	return m.patch(id, fields)
+}
+
+// Delete removes the entity, or marks it as deleted when T has a deleted_at column.
+func (m *MemoryRepository[I, T]) Delete(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	row, err := m.lookup(id)
+	if err != nil {
+		return err
+	}
+	if row == nil {
+		return fmt.Errorf("entity with id %s: %w", id, ErrNotFound)
+	}
+
+	m.remove([]*T{row})
+	return nil
+}
+
+// DeleteMany removes the matching entities, or marks them as deleted when T has a deleted_at column.
+func (m *MemoryRepository[I, T]) DeleteMany(ctx context.Context, filters []Filter) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	if _, _, err := m.schema.buildWhereClause(filters, ExcludeDeleted); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rows, err := m.match(filters, ExcludeDeleted)
+	if err != nil {
+		return 0, err
+	}
+
+	m.remove(rows)
+	return int64(len(rows)), nil
+}
+
+func (m *MemoryRepository[I, T]) Count(ctx context.Context, filters []Filter, opts *QueryOptions) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	if _, _, err := m.schema.buildWhereClause(filters, scopeOf(opts)); err != nil {
+		return 0, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rows, err := m.match(filters, scopeOf(opts))
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(len(rows)), nil
+}
+
+func (m *MemoryRepository[I, T]) Exists(ctx context.Context, filters []Filter, opts *QueryOptions) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	count, err := m.Count(ctx, filters, opts)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (m *MemoryRepository[I, T]) ExistsWithID(ctx context.Context, id string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	filters := []Filter{{Field: "id", Operator: "=", Value: id}}
+	return m.Exists(ctx, filters, nil)
+}
+
+func (m *MemoryRepository[I, T]) FindWithPagination(ctx context.Context, filters []Filter, page, pageSize int, orderBy string) ([]*T, int64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+
+	totalCount, err := m.Count(ctx, filters, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get total count: %w", err)
+	}
+
+	opts := &QueryOptions{
+		OrderBy: orderBy,
+		Limit:   pageSize,
+		Offset:  (page - 1) * pageSize,
+	}
+
+	entities, err := m.Find(ctx, filters, opts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get paginated results: %w", err)
+	}
+
+	return entities, totalCount, nil
+}
+
+func (m *MemoryRepository[I, T]) FindPage(ctx context.Context, filters []Filter, q CursorQuery) (*Page[T], error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return m.schema.findPage(ctx, filters, q, m.Find, m.Count)
+}
+
+// Stream iterates the rows Find returns, see GenericRepository.Stream. They
+// are matched when the loop starts, later writes do not affect it.
+func (m *MemoryRepository[I, T]) Stream(ctx context.Context, filters []Filter, opts *QueryOptions) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		rows, err := m.Find(ctx, filters, opts)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+
+		for _, row := range rows {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(row, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (m *MemoryRepository[I, T]) evalRow(entity *T) evalRow {
+	return evalRow{value: reflect.ValueOf(entity).Elem(), columns: m.schema.columns, jsonColumns: m.schema.jsonColumns}
+}
+
+// match returns the stored rows in scope matching all filters.
+func (m *MemoryRepository[I, T]) match(filters []Filter, scope DeletedScope) ([]*T, error) {
+	conditions := make(andExpr, len(filters))
+	for i, filter := range filters {
+		conditions[i] = filter
+	}
+	if m.schema.softDelete {
+		switch scope {
+		case ExcludeDeleted:
+			conditions = append(conditions, IsNull(deletedAtColumn))
+		case OnlyDeleted:
+			conditions = append(conditions, IsNotNull(deletedAtColumn))
+		}
+	}
+
+	var rows []*T
+	for _, id := range m.order {
+		row := m.rows[id]
+		matched, err := conditions.eval(m.evalRow(row))
+		if err != nil {
+			return nil, err
+		}
+		if matched == triTrue {
+			rows = append(rows, row)
+		}
+	}
+
+	return rows, nil
+}
+
+// lookup returns the active row with the id, or nil.
+func (m *MemoryRepository[I, T]) lookup(id string) (*T, error) {
+	rows, err := m.match([]Filter{{Field: "id", Operator: "=", Value: id}}, ExcludeDeleted)
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	return rows[0], nil
+}
+
+func (m *MemoryRepository[I, T]) conflictExprs(candidate evalRow, columns []string) []Expr {
+	exprs := make([]Expr, len(columns))
+	for i, column := range columns {
+		value, null, _ := candidate.column(column)
+		if null {
+			// NULLs never conflict
+			exprs[i] = Or()
+			continue
+		}
+		exprs[i] = Eq(column, value)
+	}
+	return exprs
+}
+
+// sort orders the rows like ORDER BY, NULLs sort as larger than any value.
+func (m *MemoryRepository[I, T]) sort(rows []*T, keys []SortKey) error {
+	var err error
+	sort.SliceStable(rows, func(a, b int) bool {
+		if err != nil {
+			return false
+		}
+
+		var cmp int
+		cmp, err = m.compareRows(m.evalRow(rows[a]), m.evalRow(rows[b]), keys)
+		return cmp < 0
+	})
+	return err
+}
+
+func (m *MemoryRepository[I, T]) compareRows(a, b evalRow, keys []SortKey) (int, error) {
+	for _, key := range keys {
+		left, leftNull, err := a.column(key.Field)
+		if err != nil {
+			return 0, err
+		}
+		right, rightNull, err := b.column(key.Field)
+		if err != nil {
+			return 0, err
+		}
+
+		nullsFirst := key.Desc
+		switch key.Nulls {
+		case NullsFirst:
+			nullsFirst = true
+		case NullsLast:
+			nullsFirst = false
+		}
+
+		switch {
+		case leftNull && rightNull:
+			continue
+		case leftNull || rightNull:
+			if leftNull == nullsFirst {
+				return -1, nil
+			}
+			return 1, nil
+		}
+
+		cmp, err := compareValues(left, right)
+		if err != nil {
+			return 0, err
+		}
+		if key.Desc {
+			cmp = -cmp
+		}
+		if cmp != 0 {
+			return cmp, nil
+		}
+	}
+
+	return 0, nil
+}
+
+func (m *MemoryRepository[I, T]) insert(id string, entity T) *T {
+	row := m.clone(&entity)
+	m.rows[id] = row
+	m.order = append(m.order, id)
+	return m.clone(row)
+}
+
+// assign writes the entity over the row like an UPDATE of buildUpdateData:
+// nil pointers keep the stored value and the version is incremented.
+func (m *MemoryRepository[I, T]) assign(row *T, entity T) {
+	stored := reflect.ValueOf(row).Elem()
+	source := reflect.ValueOf(entity)
+	for column, index := range m.schema.columns {
+		if column == "id" || (column == versionColumn && m.schema.versioned) {
+			continue
+		}
+
+		value := source.Field(index)
+		if value.Kind() == reflect.Ptr && value.IsNil() {
+			continue
+		}
+		stored.Field(index).Set(cloneValue(value))
+	}
+
+	m.bumpVersion(stored)
+}
+
+func (m *MemoryRepository[I, T]) patch(id string, fields map[string]any) (*T, error) {
+	var expected *int64
+	for column, value := range fields {
+		if column == "id" || !m.schema.hasColumn(column) {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidPatch, column)
+		}
+
+		if column == versionColumn && m.schema.versioned {
+			version, ok := toInt64(value)
+			if !ok {
+				return nil, fmt.Errorf("%w: version must be a number", ErrInvalidPatch)
+			}
+			expected = &version
+		}
+	}
+	if len(fields) == 0 || (expected != nil && len(fields) == 1) {
+		return nil, fmt.Errorf("%w: no fields to update", ErrInvalidPatch)
+	}
+
+	row, err := m.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, fmt.Errorf("entity with id %s: %w", id, ErrNotFound)
+	}
+	if expected != nil && m.schema.entityVersion(*row) != *expected {
+		return nil, &VersionConflictError{ID: id, Expected: *expected}
+	}
+
+	// work on a copy, so a failing field leaves the row untouched
+	patched := reflect.ValueOf(m.clone(row)).Elem()
+	for column, value := range fields {
+		if column == versionColumn && m.schema.versioned {
+			continue
+		}
+		if err := setColumn(patched.Field(m.schema.columns[column]), value); err != nil {
+			return nil, fmt.Errorf("failed to patch entity with id %s, column %s: %w", id, column, err)
+		}
+	}
+	m.bumpVersion(patched)
+
+	*row = patched.Interface().(T)
+	return m.clone(row), nil
+}
+
+func (m *MemoryRepository[I, T]) bumpVersion(stored reflect.Value) {
+	if m.schema.versioned {
+		version := stored.Field(m.schema.columns[versionColumn])
+		version.Set(reflect.ValueOf(m.schema.entityVersion(stored.Interface().(T)) + 1).Convert(version.Type()))
+	}
+}
+
+// remove deletes the rows, or sets their deleted_at when T has one.
+func (m *MemoryRepository[I, T]) remove(rows []*T) {
+	now := time.Now()
+	for _, row := range rows {
+		if m.schema.softDelete {
+			field := reflect.ValueOf(row).Elem().Field(m.schema.columns[deletedAtColumn])
+			_ = setColumn(field, now)
+			continue
+		}
+
+		id := fmt.Sprint((*row).GetID())
+		delete(m.rows, id)
+		m.order = slices.DeleteFunc(m.order, func(other string) bool { return other == id })
+	}
+}
+
+// project copies the row, keeping only the selected columns when given.
+func (m *MemoryRepository[I, T]) project(row *T, columns []string) *T {
+	if len(columns) == 0 {
+		return m.clone(row)
+	}
+
+	source := reflect.ValueOf(row).Elem()
+	projected := new(T)
+	target := reflect.ValueOf(projected).Elem()
+	for _, column := range columns {
+		index := m.schema.columns[column]
+		target.Field(index).Set(cloneValue(source.Field(index)))
+	}
+	return projected
+}
+
+func (m *MemoryRepository[I, T]) clone(row *T) *T {
+	copied := cloneValue(reflect.ValueOf(row).Elem()).Interface().(T)
+	return &copied
+}
+
+// setColumn stores a column value like a parameter bound to it: nil is
+// NULL and other types are converted through their JSON representation.
+func setColumn(field reflect.Value, value any) error {
+	if value == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+
+	v := reflect.ValueOf(value)
+	switch {
+	case v.Type().AssignableTo(field.Type()):
+		field.Set(cloneValue(v))
+		return nil
+	case field.Kind() == reflect.Ptr && v.Type().AssignableTo(field.Type().Elem()):
+		pointer := reflect.New(field.Type().Elem())
+		pointer.Elem().Set(cloneValue(v))
+		field.Set(pointer)
+		return nil
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	converted := reflect.New(field.Type())
+	if err := json.Unmarshal(encoded, converted.Interface()); err != nil {
+		return fmt.Errorf("cannot store %T as %s: %w", value, field.Type(), err)
+	}
+	field.Set(converted.Elem())
+	return nil
+}
+
+// cloneValue deep copies maps, slices and pointers, so callers never share
+// memory with the stored rows.
+func cloneValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		copied := reflect.New(v.Type().Elem())
+		copied.Elem().Set(cloneValue(v.Elem()))
+		return copied
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(cloneValue(v.Elem()))
+		return copied
+
+	case reflect.Map:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			copied.SetMapIndex(iter.Key(), cloneValue(iter.Value()))
+		}
+		return copied
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := range v.Len() {
+			copied.Index(i).Set(cloneValue(v.Index(i)))
+		}
+		return copied
+
+	case reflect.Struct:
+		// unexported fields, e.g. of time.Time, are copied as they are
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(v)
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() {
+				copied.Field(i).Set(cloneValue(v.Field(i)))
+			}
+		}
+		return copied
+
+	default:
+		return v
+	}
+}
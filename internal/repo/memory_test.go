@@ -0,0 +1,56 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/xligenda/ods-servers/internal/structs"
+)
+
+func TestMemoryCancelledContext(t *testing.T) {
+	r := NewMemoryRepository[string, structs.Server]("servers")
+	server := createServer(t, r, structs.ServerRoles{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := map[string]func() error{
+		"Find":               func() error { _, err := r.Find(ctx, nil, nil); return err },
+		"FindOne":            func() error { _, err := r.FindOne(ctx, nil); return err },
+		"FindByID":           func() error { _, err := r.FindByID(ctx, "1"); return err },
+		"Create":             func() error { _, err := r.Create(ctx, structs.Server{Tag: 2}); return err },
+		"CreateMany":         func() error { _, err := r.CreateMany(ctx, []structs.Server{{Tag: 2}}, nil); return err },
+		"Update":             func() error { _, err := r.Update(ctx, "1", *server); return err },
+		"Upsert":             func() error { _, err := r.Upsert(ctx, *server, nil); return err },
+		"Patch":              func() error { _, err := r.Patch(ctx, "1", map[string]any{"guild": 11}); return err },
+		"MergePatch":         func() error { _, err := r.MergePatch(ctx, "1", []byte(`{"guild": 11}`)); return err },
+		"Delete":             func() error { return r.Delete(ctx, "1") },
+		"DeleteMany":         func() error { _, err := r.DeleteMany(ctx, nil); return err },
+		"Count":              func() error { _, err := r.Count(ctx, nil, nil); return err },
+		"Exists":             func() error { _, err := r.Exists(ctx, nil, nil); return err },
+		"ExistsWithID":       func() error { _, err := r.ExistsWithID(ctx, "1"); return err },
+		"FindWithPagination": func() error { _, _, err := r.FindWithPagination(ctx, nil, 1, 10, ""); return err },
+		"FindPage":           func() error { _, err := r.FindPage(ctx, nil, CursorQuery{}); return err },
+	}
+
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			if err := call(); !errors.Is(err, context.Canceled) {
+				t.Errorf("err = %v, want context.Canceled", err)
+			}
+		})
+	}
+
+	// nothing was written
+	stored, err := r.FindByID(context.Background(), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored == nil || stored.Guild != server.Guild {
+		t.Errorf("stored = %+v, want the unchanged server", stored)
+	}
+	if count, _ := r.Count(context.Background(), nil, nil); count != 1 {
+		t.Errorf("count = %d, want 1", count)
+	}
+}
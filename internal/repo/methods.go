@@ -3,20 +3,22 @@ package repo
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"reflect"
 	"strings"
-
-	"github.com/jmoiron/sqlx"
-	"github.com/lib/pq"
 )
 
 func (r *GenericRepository[I, T]) Find(ctx context.Context, filters []Filter, opts *QueryOptions) ([]*T, error) {
-	query, args := r.buildSelectQuery(filters, opts)
+	query, args, err := r.buildSelectQuery(filters, opts)
+	if err != nil {
+		return nil, err
+	}
 
 	var entities []T
-	err := r.db.SelectContext(ctx, &entities, query, args...)
+	err = r.conn(ctx, "Find").SelectContext(ctx, &entities, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute query: %w", err)
+		return nil, wrapError("failed to execute query", err)
 	}
 
 	result := make([]*T, len(entities))
@@ -29,15 +31,18 @@ func (r *GenericRepository[I, T]) Find(ctx context.Context, filters []Filter, op
 
 func (r *GenericRepository[I, T]) FindOne(ctx context.Context, filters []Filter) (*T, error) {
 	opts := &QueryOptions{Limit: 1}
-	query, args := r.buildSelectQuery(filters, opts)
+	query, args, err := r.buildSelectQuery(filters, opts)
+	if err != nil {
+		return nil, err
+	}
 
 	var entity T
-	err := r.db.GetContext(ctx, &entity, query, args...)
+	err = r.conn(ctx, "FindOne").GetContext(ctx, &entity, query, args...)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
-		return nil, fmt.Errorf("failed to scan row: %w", err)
+		return nil, wrapError("failed to scan row", err)
 	}
 
 	return &entity, nil
@@ -49,126 +54,236 @@ func (r *GenericRepository[I, T]) FindByID(ctx context.Context, id string) (*T,
 }
 
 func (r *GenericRepository[I, T]) Create(ctx context.Context, entity T) (*T, error) {
+	return r.auditedOne(ctx, "Create", nil, func(ctx context.Context) (*T, error) {
+		return r.create(ctx, entity)
+	})
+}
+
+func (r *GenericRepository[I, T]) create(ctx context.Context, entity T) (*T, error) {
 	fields, values, placeholders := r.buildInsertData(entity)
 
 	query := fmt.Sprintf(
-		"INSERT INTO %s (%s) VALUES (%s) RETURNING *",
-		pq.QuoteIdentifier(r.tableName),
+		"INSERT INTO %s (%s) VALUES (%s)%s",
+		r.dialect.quote(r.tableName),
 		strings.Join(fields, ", "),
 		strings.Join(placeholders, ", "),
+		r.dialect.returning(),
 	)
 
 	var createdEntity T
-	err := r.db.GetContext(ctx, &createdEntity, query, values...)
+	err := r.conn(ctx, "Create").GetContext(ctx, &createdEntity, query, values...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create entity: %w", err)
+		return nil, wrapError("failed to create entity", err)
 	}
 
 	return &createdEntity, nil
 }
 
+// Update writes the entity. With a version column the write only succeeds
+// when the stored version equals the entity's, and increments it.
 func (r *GenericRepository[I, T]) Update(ctx context.Context, id string, entity T) (*T, error) {
+	return r.auditedOne(ctx, "Update", idScope(id), func(ctx context.Context) (*T, error) {
+		return r.update(ctx, id, entity)
+	})
+}
+
+func (r *GenericRepository[I, T]) update(ctx context.Context, id string, entity T) (*T, error) {
 	fields, values := r.buildUpdateData(entity)
 	if len(fields) == 0 {
-		return nil, fmt.Errorf("no fields to update")
+		return nil, fmt.Errorf("%w: no fields to update", ErrInvalidPatch)
+	}
+
+	whereClause := "id = " + r.dialect.placeholder(len(values)+1)
+	values = append(values, id)
+	if r.versioned {
+		fields = append(fields, r.versionIncrement(false))
+		whereClause += fmt.Sprintf(" AND %s = %s", r.dialect.quote(versionColumn), r.dialect.placeholder(len(values)+1))
+		values = append(values, r.entityVersion(entity))
 	}
 
 	setClause := strings.Join(fields, ", ")
 	query := fmt.Sprintf(
-		"UPDATE %s SET %s WHERE id = $%d RETURNING *",
-		pq.QuoteIdentifier(r.tableName),
+		"UPDATE %s SET %s WHERE %s%s%s",
+		r.dialect.quote(r.tableName),
 		setClause,
-		len(values)+1,
+		whereClause,
+		r.activeClause(),
+		r.dialect.returning(),
 	)
 
-	values = append(values, id)
 	var updatedEntity T
-	err := r.db.GetContext(ctx, &updatedEntity, query, values...)
+	err := r.conn(ctx, "Update").GetContext(ctx, &updatedEntity, query, values...)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("entity with id %s not found", id)
+		if errors.Is(err, sql.ErrNoRows) {
+			if r.versioned {
+				return nil, r.missingRowError(ctx, id, r.entityVersion(entity))
+			}
+			return nil, fmt.Errorf("entity with id %s: %w", id, ErrNotFound)
 		}
-		return nil, fmt.Errorf("failed to update entity: %w", err)
+		return nil, wrapError("failed to update entity", err)
 	}
 
 	return &updatedEntity, nil
 }
 
 func (r *GenericRepository[I, T]) Upsert(ctx context.Context, entity T, conflictColumns []string) (*T, error) {
-	fields, values, placeholders := r.buildInsertData(entity)
-	updateFields, _ := r.buildUpdateData(entity)
-
 	if len(conflictColumns) == 0 {
 		conflictColumns = []string{"id"}
 	}
 
-	conflictClause := strings.Join(conflictColumns, ", ")
-	updateClause := strings.Join(updateFields, ", ")
+	for _, column := range conflictColumns {
+		if !r.hasColumn(column) {
+			return nil, &InvalidFilterError{Field: column, Reason: "unknown conflict column"}
+		}
+	}
+
+	return r.auditedOne(ctx, "Upsert", r.keyScope([]T{entity}, conflictColumns), func(ctx context.Context) (*T, error) {
+		return r.upsert(ctx, entity, conflictColumns)
+	})
+}
+
+func (r *GenericRepository[I, T]) upsert(ctx context.Context, entity T, conflictColumns []string) (*T, error) {
+	fields, values, placeholders := r.buildInsertData(entity)
+
+	quotedConflict := make([]string, len(conflictColumns))
+	for i, column := range conflictColumns {
+		quotedConflict[i] = r.dialect.quote(column)
+	}
+
+	// the proposed row carries the values, so the update binds nothing
+	updateFields := make([]string, 0, len(fields))
+	for _, column := range r.columnNames() {
+		if column == "id" || (column == versionColumn && r.versioned) {
+			continue
+		}
+		if field := reflect.ValueOf(entity).Field(r.columns[column]); field.Kind() == reflect.Ptr && field.IsNil() {
+			continue
+		}
+		updateFields = append(updateFields, fmt.Sprintf("%s = %s", r.dialect.quote(column), r.dialect.excluded(column)))
+	}
+
+	conflictWhere := ""
+	if r.versioned {
+		updateFields = append(updateFields, r.versionIncrement(true))
+		conflictWhere = fmt.Sprintf(
+			"%s.%s = %s",
+			r.dialect.quote(r.tableName),
+			r.dialect.quote(versionColumn),
+			r.dialect.excluded(versionColumn),
+		)
+	}
 
 	query := fmt.Sprintf(
-		"INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET %s RETURNING *",
-		pq.QuoteIdentifier(r.tableName),
+		"INSERT INTO %s (%s) VALUES (%s)%s%s",
+		r.dialect.quote(r.tableName),
 		strings.Join(fields, ", "),
 		strings.Join(placeholders, ", "),
-		conflictClause,
-		updateClause,
+		r.dialect.onConflict(quotedConflict, updateFields, conflictWhere),
+		r.dialect.returning(),
 	)
 
 	var upsertedEntity T
-	err := r.db.GetContext(ctx, &upsertedEntity, query, values...)
+	err := r.conn(ctx, "Upsert").GetContext(ctx, &upsertedEntity, query, values...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to upsert entity: %w", err)
+		// the row exists, otherwise it would have been inserted
+		if errors.Is(err, sql.ErrNoRows) && r.versioned {
+			return nil, &VersionConflictError{ID: fmt.Sprint(entity.GetID()), Expected: r.entityVersion(entity)}
+		}
+		return nil, wrapError("failed to upsert entity", err)
 	}
 
 	return &upsertedEntity, nil
 }
 
+// Delete removes the entity, or marks it as deleted when T has a deleted_at column.
 func (r *GenericRepository[I, T]) Delete(ctx context.Context, id string) error {
-	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1", pq.QuoteIdentifier(r.tableName))
-	result, err := r.db.ExecContext(ctx, query, id)
+	return r.audited(ctx, "Delete", idScope(id), func(ctx context.Context) ([]*T, error) {
+		return nil, r.deleteByID(ctx, id)
+	})
+}
+
+func (r *GenericRepository[I, T]) deleteByID(ctx context.Context, id string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = %s", r.dialect.quote(r.tableName), r.dialect.placeholder(1))
+	if r.softDelete {
+		query = fmt.Sprintf(
+			"UPDATE %s SET %s = %s WHERE id = %s%s",
+			r.dialect.quote(r.tableName),
+			r.dialect.quote(deletedAtColumn),
+			r.dialect.now(),
+			r.dialect.placeholder(1),
+			r.activeClause(),
+		)
+	}
+	result, err := r.conn(ctx, "Delete").ExecContext(ctx, query, id)
 	if err != nil {
-		return fmt.Errorf("failed to delete entity: %w", err)
+		return wrapError("failed to delete entity", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to get affected rows: %w", err)
+		return wrapError("failed to get affected rows", err)
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("entity with id %s not found", id)
+		return fmt.Errorf("entity with id %s: %w", id, ErrNotFound)
 	}
 
 	return nil
 }
 
+// DeleteMany removes the matching entities, or marks them as deleted when T has a deleted_at column.
 func (r *GenericRepository[I, T]) DeleteMany(ctx context.Context, filters []Filter) (int64, error) {
-	whereClause, args := r.buildWhereClause(filters)
-	query := fmt.Sprintf("DELETE FROM %s%s", pq.QuoteIdentifier(r.tableName), whereClause)
+	var deleted int64
+	err := r.audited(ctx, "DeleteMany", &auditScope{filters: filters}, func(ctx context.Context) ([]*T, error) {
+		var err error
+		deleted, err = r.deleteMany(ctx, filters)
+		return nil, err
+	})
+	return deleted, err
+}
+
+func (r *GenericRepository[I, T]) deleteMany(ctx context.Context, filters []Filter) (int64, error) {
+	whereClause, args, err := r.buildWhereClause(filters, ExcludeDeleted)
+	if err != nil {
+		return 0, err
+	}
+	query := fmt.Sprintf("DELETE FROM %s%s", r.dialect.quote(r.tableName), whereClause)
+	if r.softDelete {
+		query = fmt.Sprintf(
+			"UPDATE %s SET %s = %s%s",
+			r.dialect.quote(r.tableName),
+			r.dialect.quote(deletedAtColumn),
+			r.dialect.now(),
+			whereClause,
+		)
+	}
 
-	result, err := r.db.ExecContext(ctx, query, args...)
+	result, err := r.conn(ctx, "DeleteMany").ExecContext(ctx, query, args...)
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete entities: %w", err)
+		return 0, wrapError("failed to delete entities", err)
 	}
 
 	return result.RowsAffected()
 }
 
-func (r *GenericRepository[I, T]) Count(ctx context.Context, filters []Filter) (int64, error) {
-	whereClause, args := r.buildWhereClause(filters)
-	query := fmt.Sprintf("SELECT COUNT(*) FROM %s%s", pq.QuoteIdentifier(r.tableName), whereClause)
+func (r *GenericRepository[I, T]) Count(ctx context.Context, filters []Filter, opts *QueryOptions) (int64, error) {
+	whereClause, args, err := r.buildWhereClause(filters, scopeOf(opts))
+	if err != nil {
+		return 0, err
+	}
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s%s", r.dialect.quote(r.tableName), whereClause)
 
 	var count int64
-	err := r.db.QueryRowContext(ctx, query, args...).Scan(&count)
+	err = r.conn(ctx, "Count").GetContext(ctx, &count, query, args...)
 	if err != nil {
-		return 0, fmt.Errorf("failed to count entities: %w", err)
+		return 0, wrapError("failed to count entities", err)
 	}
 
 	return count, nil
 }
 
-func (r *GenericRepository[I, T]) Exists(ctx context.Context, filters []Filter) (bool, error) {
-	count, err := r.Count(ctx, filters)
+func (r *GenericRepository[I, T]) Exists(ctx context.Context, filters []Filter, opts *QueryOptions) (bool, error) {
+	count, err := r.Count(ctx, filters, opts)
 	if err != nil {
 		return false, err
 	}
@@ -177,7 +292,7 @@ func (r *GenericRepository[I, T]) Exists(ctx context.Context, filters []Filter)
 
 func (r *GenericRepository[I, T]) ExistsWithID(ctx context.Context, id string) (bool, error) {
 	filters := []Filter{{Field: "id", Operator: "=", Value: id}}
-	return r.Exists(ctx, filters)
+	return r.Exists(ctx, filters, nil)
 }
 
 func (r *GenericRepository[I, T]) FindWithPagination(ctx context.Context, filters []Filter, page, pageSize int, orderBy string) ([]*T, int64, error) {
@@ -188,7 +303,7 @@ func (r *GenericRepository[I, T]) FindWithPagination(ctx context.Context, filter
 		pageSize = 10
 	}
 
-	totalCount, err := r.Count(ctx, filters)
+	totalCount, err := r.Count(ctx, filters, nil)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to get total count: %w", err)
 	}
@@ -206,23 +321,3 @@ func (r *GenericRepository[I, T]) FindWithPagination(ctx context.Context, filter
 
 	return entities, totalCount, nil
 }
-
-func (r *GenericRepository[I, T]) Transaction(ctx context.Context, fn func(*sqlx.Tx) error) error {
-	tx, err := r.db.BeginTxx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-
-	if err := fn(tx); err != nil {
-		if rollbackErr := tx.Rollback(); rollbackErr != nil {
-			return fmt.Errorf("transaction error: %w, rollback error: %w", err, rollbackErr)
-		}
-		return err
-	}
-
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
-	return nil
-}
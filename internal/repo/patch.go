@@ -0,0 +1,236 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var ErrInvalidPatch = errors.New("invalid patch")
+
+// Patch updates only the given columns, a nil value sets the column to NULL.
+// Unlike Update, zero values are written as well. With a version column a
+// "version" field is the expected version rather than a new value.
+func (r *GenericRepository[I, T]) Patch(ctx context.Context, id string, fields map[string]any) (*T, error) {
+	return r.auditedOne(ctx, "Patch", idScope(id), func(ctx context.Context) (*T, error) {
+		return r.patch(ctx, id, fields)
+	})
+}
+
+func (r *GenericRepository[I, T]) patch(ctx context.Context, id string, fields map[string]any) (*T, error) {
+	var expected *int64
+	columns := make([]string, 0, len(fields))
+	for column, value := range fields {
+		if column == "id" || !r.hasColumn(column) {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidPatch, column)
+		}
+
+		if column == versionColumn && r.versioned {
+			version, ok := toInt64(value)
+			if !ok {
+				return nil, fmt.Errorf("%w: version must be a number", ErrInvalidPatch)
+			}
+			expected = &version
+			continue
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("%w: no fields to update", ErrInvalidPatch)
+	}
+
+	b := r.newSQLBuilder()
+	setClause := make([]string, len(columns), len(columns)+1)
+	for i, column := range columns {
+		setClause[i] = fmt.Sprintf("%s = %s", r.dialect.quote(column), b.bind(r.getFieldValue(reflect.ValueOf(fields[column]))))
+	}
+
+	whereClause := "id = " + b.bind(id)
+	if r.versioned {
+		setClause = append(setClause, r.versionIncrement(false))
+		if expected != nil {
+			whereClause += fmt.Sprintf(" AND %s = %s", r.dialect.quote(versionColumn), b.bind(*expected))
+		}
+	}
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s WHERE %s%s%s",
+		r.dialect.quote(r.tableName),
+		strings.Join(setClause, ", "),
+		whereClause,
+		r.activeClause(),
+		r.dialect.returning(),
+	)
+
+	var patchedEntity T
+	err := r.conn(ctx, "Patch").GetContext(ctx, &patchedEntity, query, b.args...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) && expected != nil {
+			return nil, r.missingRowError(ctx, id, *expected)
+		}
+		return nil, wrapError(fmt.Sprintf("failed to patch entity with id %s", id), err)
+	}
+
+	return &patchedEntity, nil
+}
+
+// MergePatch applies a JSON Merge Patch (RFC 7386) addressed by the json
+// names of T: null clears a column, objects are merged into JSON columns
+// and any other value replaces the column. deleted_at cannot be patched. The row is locked while the
+// patch is merged, and without a "version" member the version it was read
+// at is expected, so a concurrent write is never merged away silently.
+func (r *GenericRepository[I, T]) MergePatch(ctx context.Context, id string, patch []byte) (*T, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &members); err != nil || members == nil {
+		return nil, fmt.Errorf("%w: merge patch must be a JSON object", ErrInvalidPatch)
+	}
+
+	var patched *T
+	err := r.Transaction(ctx, nil, func(ctx context.Context) error {
+		rows, err := r.lockRows(ctx, "MergePatch", idScope(id))
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return fmt.Errorf("entity with id %s: %w", id, ErrNotFound)
+		}
+		current := rows[0]
+
+		fields, err := r.mergeFields(reflect.ValueOf(current).Elem(), members)
+		if err != nil {
+			return err
+		}
+		if len(fields) == 0 {
+			patched = current
+			return nil
+		}
+		// without a row lock, e.g. on SQLite, the version check detects a stale read
+		if _, ok := fields[versionColumn]; r.versioned && !ok {
+			fields[versionColumn] = r.entityVersion(*current)
+		}
+
+		patched, err = r.Patch(ctx, id, fields)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return patched, nil
+}
+
+// mergeFields resolves the patch members into column values of the current entity.
+func (r *GenericRepository[I, T]) mergeFields(current reflect.Value, members map[string]json.RawMessage) (map[string]any, error) {
+	byJSONName := make(map[string]string, len(r.columns))
+	for column, index := range r.columns {
+		byJSONName[jsonName(current.Type().Field(index))] = column
+	}
+
+	fields := make(map[string]any, len(members))
+	for name, raw := range members {
+		column, ok := byJSONName[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidPatch, name)
+		}
+		field := current.Field(r.columns[column])
+
+		// deleting and restoring go through Delete and Restore
+		if column == deletedAtColumn && r.softDelete {
+			return nil, fmt.Errorf("%w: %q cannot be patched", ErrInvalidPatch, name)
+		}
+
+		if column == "id" {
+			// the id is only accepted when it matches the addressed entity
+			if !jsonEqual(field.Interface(), raw) {
+				return nil, fmt.Errorf("%w: %q cannot be changed", ErrInvalidPatch, name)
+			}
+			continue
+		}
+
+		if string(raw) == "null" {
+			fields[column] = nil
+			continue
+		}
+
+		if r.jsonColumns[column] && strings.HasPrefix(strings.TrimSpace(string(raw)), "{") {
+			merged, err := mergeJSON(field.Interface(), raw)
+			if err != nil {
+				return nil, fmt.Errorf("%w: field %q: %v", ErrInvalidPatch, name, err)
+			}
+			raw = merged
+		}
+
+		value := reflect.New(field.Type())
+		if err := json.Unmarshal(raw, value.Interface()); err != nil {
+			return nil, fmt.Errorf("%w: field %q: %v", ErrInvalidPatch, name, err)
+		}
+		fields[column] = value.Elem().Interface()
+	}
+
+	return fields, nil
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+func jsonEqual(value any, raw json.RawMessage) bool {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return false
+	}
+
+	var a, b any
+	return json.Unmarshal(encoded, &a) == nil && json.Unmarshal(raw, &b) == nil && reflect.DeepEqual(a, b)
+}
+
+func mergeJSON(current any, patch json.RawMessage) (json.RawMessage, error) {
+	encoded, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+
+	var target, changes any
+	if err := json.Unmarshal(encoded, &target); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patch, &changes); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(mergeValue(target, changes))
+}
+
+// mergeValue implements the MergePatch algorithm of RFC 7386.
+func mergeValue(target, patch any) any {
+	changes, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	object, ok := target.(map[string]any)
+	if !ok {
+		object = make(map[string]any)
+	}
+
+	for key, value := range changes {
+		if value == nil {
+			delete(object, key)
+			continue
+		}
+		object[key] = mergeValue(object[key], value)
+	}
+
+	return object
+}
@@ -0,0 +1,143 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/xligenda/ods-servers/internal/structs"
+)
+
+func createServer(t *testing.T, r Repository[string, structs.Server], roles structs.ServerRoles) *structs.Server {
+	t.Helper()
+
+	server, err := r.Create(context.Background(), structs.Server{Tag: 1, Guild: 10, InviteChannel: 20, Roles: roles})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server
+}
+
+func TestMergePatchConcurrentRoleEdits(t *testing.T) {
+	// both calls read the row before either of them writes
+	var reads atomic.Int32
+	barrier := make(chan struct{})
+	hook := HookFunc(func(ctx context.Context, event QueryEvent) {
+		if event.Operation == "MergePatch" && reads.Add(1) <= 2 {
+			if reads.Load() == 2 {
+				close(barrier)
+			}
+			<-barrier
+		}
+	})
+
+	r := NewRepository[string, structs.Server](openTestDBFile(t), "servers", WithHooks(hook))
+	createServer(t, r, structs.ServerRoles{})
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = r.MergePatch(context.Background(), "1", []byte(fmt.Sprintf(`{"roles": {"%d": "Admin"}}`, 100+i)))
+		}()
+	}
+	wg.Wait()
+
+	stored, err := r.FindByID(context.Background(), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	succeeded := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+			if stored.Roles[structs.DiscordID(100+i)] != "Admin" {
+				t.Errorf("role of patch %d was lost: %v", i, stored.Roles)
+			}
+		case !errors.Is(err, ErrVersionConflict):
+			t.Errorf("patch %d: %v", i, err)
+		}
+	}
+	if succeeded == 0 {
+		t.Fatal("no patch succeeded")
+	}
+	if stored.Version != int64(succeeded) {
+		t.Errorf("version = %d, want %d", stored.Version, succeeded)
+	}
+}
+
+func TestMergePatchStaleRead(t *testing.T) {
+	var r *GenericRepository[string, structs.Server]
+	var interleaved atomic.Bool
+	// a second admin's patch lands between the read and the write of the first
+	hook := HookFunc(func(ctx context.Context, event QueryEvent) {
+		if event.Operation == "MergePatch" && interleaved.CompareAndSwap(false, true) {
+			if _, err := r.MergePatch(ctx, "1", []byte(`{"roles": {"200": "Moderator"}}`)); err != nil {
+				t.Errorf("interleaved patch: %v", err)
+			}
+		}
+	})
+
+	r = NewRepository[string, structs.Server](openTestDB(t), "servers", WithHooks(hook))
+	createServer(t, r, structs.ServerRoles{})
+
+	_, err := r.MergePatch(context.Background(), "1", []byte(`{"roles": {"100": "Admin"}}`))
+	var conflict *VersionConflictError
+	if !errors.As(err, &conflict) || conflict.Expected != 0 {
+		t.Fatalf("err = %v, want a conflict expecting version 0", err)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		patch   string
+		want    structs.ServerRoles
+		version int64
+		err     error
+	}{
+		{name: "adds role", patch: `{"roles": {"3": "Moderator"}}`, want: structs.ServerRoles{1: "Admin", 2: "Helper", 3: "Moderator"}, version: 1},
+		{name: "removes role", patch: `{"roles": {"2": null}}`, want: structs.ServerRoles{1: "Admin"}, version: 1},
+		{name: "replaces roles", patch: `{"roles": {"1": "Owner"}}`, want: structs.ServerRoles{1: "Owner", 2: "Helper"}, version: 1},
+		{name: "current version", patch: `{"roles": {"2": null}, "version": 0}`, want: structs.ServerRoles{1: "Admin"}, version: 1},
+		{name: "stale version", patch: `{"roles": {"2": null}, "version": 5}`, err: ErrVersionConflict},
+		{name: "unknown field", patch: `{"name": "x"}`, err: ErrInvalidPatch},
+		{name: "changed id", patch: `{"tag": 2}`, err: ErrInvalidPatch},
+		{name: "not an object", patch: `[]`, err: ErrInvalidPatch},
+		{name: "soft delete", patch: `{"deleted_at": "2026-01-01T00:00:00Z"}`, err: ErrInvalidPatch},
+		{name: "restore", patch: `{"deleted_at": null}`, err: ErrInvalidPatch},
+		{name: "no changes", patch: `{}`, want: structs.ServerRoles{1: "Admin", 2: "Helper"}, version: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, r := range map[string]Repository[string, structs.Server]{
+				"sql":    NewRepository[string, structs.Server](openTestDB(t), "servers"),
+				"memory": NewMemoryRepository[string, structs.Server]("servers"),
+			} {
+				createServer(t, r, structs.ServerRoles{1: "Admin", 2: "Helper"})
+
+				patched, err := r.MergePatch(context.Background(), "1", []byte(tt.patch))
+				if tt.err != nil {
+					if !errors.Is(err, tt.err) {
+						t.Errorf("%s: err = %v, want %v", name, err, tt.err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				if fmt.Sprint(patched.Roles) != fmt.Sprint(tt.want) || patched.Version != tt.version {
+					t.Errorf("%s: got %v at version %d, want %v at version %d", name, patched.Roles, patched.Version, tt.want, tt.version)
+				}
+			}
+		})
+	}
+}
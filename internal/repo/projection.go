@@ -0,0 +1,121 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// FindInto runs Find scanning the rows into R instead of T. The db tags of R
+// must all be columns of T, without explicit columns in opts only they are
+// selected. Other Repository implementations run Find and copy the
+// selected columns into R by their db tags.
+//
+//	type serverSummary struct {
+//		Tag   structs.ServerTag `db:"id"`
+//		Guild structs.DiscordID `db:"guild"`
+//	}
+//	summaries, err := repo.FindInto[serverSummary](ctx, servers, nil, nil)
+func FindInto[R any, I IDsConstraint, T StructsConstraint[I]](ctx context.Context, r Repository[I, T], filters []Filter, opts *QueryOptions) ([]*R, error) {
+	projected := QueryOptions{}
+	if opts != nil {
+		projected = *opts
+	}
+
+	known := entityColumns(reflect.TypeOf((*T)(nil)).Elem())
+	columns := entityColumns(reflect.TypeOf((*R)(nil)).Elem())
+	for column := range columns {
+		if _, ok := known[column]; !ok {
+			return nil, &InvalidFilterError{Field: column, Reason: "unknown column"}
+		}
+	}
+
+	if len(projected.Columns) == 0 {
+		for column := range columns {
+			projected.Columns = append(projected.Columns, column)
+		}
+		sort.Slice(projected.Columns, func(a, b int) bool {
+			return columns[projected.Columns[a]] < columns[projected.Columns[b]]
+		})
+	}
+
+	sqlRepo, ok := r.(*GenericRepository[I, T])
+	if !ok {
+		return copyInto[R](r.Find(ctx, filters, &projected))
+	}
+
+	query, args, err := sqlRepo.buildSelectQuery(filters, &projected)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []R
+	if err := sqlRepo.conn(ctx, "FindInto").SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, wrapError("failed to execute query", err)
+	}
+
+	result := make([]*R, len(rows))
+	for i := range rows {
+		result[i] = &rows[i]
+	}
+
+	return result, nil
+}
+
+// copyInto converts entities into R, assigning the fields sharing a db tag.
+func copyInto[R any, T any](entities []*T, err error) ([]*R, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	targetColumns := entityColumns(reflect.TypeOf((*R)(nil)).Elem())
+	sourceColumns := entityColumns(reflect.TypeOf((*T)(nil)).Elem())
+
+	result := make([]*R, len(entities))
+	for i, entity := range entities {
+		source := reflect.ValueOf(entity).Elem()
+		target := reflect.ValueOf(new(R)).Elem()
+		for column, index := range targetColumns {
+			sourceIndex, ok := sourceColumns[column]
+			if !ok {
+				return nil, &InvalidFilterError{Field: column, Reason: "unknown column"}
+			}
+			value := source.Field(sourceIndex)
+			field := target.Field(index)
+			switch {
+			case value.Type().AssignableTo(field.Type()):
+				field.Set(cloneValue(value))
+			case isNumber(value.Type()) && isNumber(field.Type()) && convertsLosslessly(value, field.Type()):
+				field.Set(value.Convert(field.Type()))
+			default:
+				// other conversions, e.g. of an int into a string, would not
+				// match what the database driver scans
+				return nil, fmt.Errorf("cannot scan column %s of type %s into %s", column, value.Type(), field.Type())
+			}
+		}
+		result[i] = target.Addr().Interface().(*R)
+	}
+
+	return result, nil
+}
+
+func isNumber(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// convertsLosslessly reports whether the number keeps its value in type t,
+// a driver rejects values out of the range of the destination as well.
+func convertsLosslessly(value reflect.Value, t reflect.Type) bool {
+	negative := value.CanInt() && value.Int() < 0 || value.CanFloat() && value.Float() < 0
+	if negative && reflect.Zero(t).CanUint() {
+		return false
+	}
+	return value.Convert(t).Convert(value.Type()).Equal(value)
+}
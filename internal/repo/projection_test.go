@@ -0,0 +1,103 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/xligenda/ods-servers/internal/structs"
+)
+
+func TestFindInto(t *testing.T) {
+	type summary struct {
+		Tag   structs.ServerTag `db:"id"`
+		Guild structs.DiscordID `db:"guild"`
+	}
+	type unknown struct {
+		Tag  structs.ServerTag `db:"id"`
+		Name string            `db:"name"`
+	}
+
+	for name, r := range map[string]Repository[string, structs.Server]{
+		"sql":    NewRepository[string, structs.Server](openTestDB(t), "servers"),
+		"memory": NewMemoryRepository[string, structs.Server]("servers"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			createServer(t, r, structs.ServerRoles{})
+			ctx := context.Background()
+
+			summaries, err := FindInto[summary](ctx, r, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(summaries) != 1 || *summaries[0] != (summary{Tag: 1, Guild: 10}) {
+				t.Errorf("summaries = %+v", summaries)
+			}
+
+			_, err = FindInto[unknown](ctx, r, nil, nil)
+			if !errors.Is(err, ErrInvalidFilter) {
+				t.Errorf("without columns: err = %v, want ErrInvalidFilter", err)
+			}
+			_, err = FindInto[unknown](ctx, r, nil, NewQueryOptions().WithColumns("id"))
+			if !errors.Is(err, ErrInvalidFilter) {
+				t.Errorf("with columns: err = %v, want ErrInvalidFilter", err)
+			}
+		})
+	}
+}
+
+func TestCopyInto(t *testing.T) {
+	type source struct {
+		ID     int     `db:"id"`
+		Count  int32   `db:"count"`
+		Signed int     `db:"signed"`
+		Ratio  float64 `db:"ratio"`
+	}
+	entities := []*source{{ID: 5, Count: 7, Signed: -1, Ratio: 1.5}}
+
+	type widened struct {
+		Count int64   `db:"count"`
+		Ratio float64 `db:"ratio"`
+	}
+	copied, err := copyInto[widened](entities, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *copied[0] != (widened{Count: 7, Ratio: 1.5}) {
+		t.Errorf("copied = %+v", *copied[0])
+	}
+
+	tests := map[string]func() error{
+		// Go would convert 5 into "\x05", the database driver scans "5"
+		"int into string": func() error {
+			_, err := copyInto[struct {
+				ID string `db:"id"`
+			}](entities, nil)
+			return err
+		},
+		"negative into unsigned": func() error {
+			_, err := copyInto[struct {
+				Signed uint `db:"signed"`
+			}](entities, nil)
+			return err
+		},
+		"fraction into int": func() error {
+			_, err := copyInto[struct {
+				Ratio int `db:"ratio"`
+			}](entities, nil)
+			return err
+		},
+		"out of range": func() error {
+			_, err := copyInto[struct {
+				ID int8 `db:"id"`
+			}]([]*source{{ID: 300}}, nil)
+			return err
+		},
+	}
+	for name, copy := range tests {
+		if err := copy(); err == nil || !strings.Contains(err.Error(), "cannot scan") {
+			t.Errorf("%s: err = %v, want a scan error", name, err)
+		}
+	}
+}
@@ -1,6 +1,12 @@
 package repo
 
 import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"iter"
+	"reflect"
+
 	"github.com/jmoiron/sqlx"
 	"github.com/xligenda/ods-servers/internal/structs"
 )
@@ -10,31 +16,132 @@ type IDsConstraint interface {
 }
 
 type StructsConstraint[I IDsConstraint] interface {
-	structs.Server
+	structs.Server | structs.User | structs.Invite | structs.InviteSnapshot
 	GetID() I
 }
 
+// Repository stores entities of T. GenericRepository implements it on
+// Postgres and MemoryRepository in memory for tests.
+type Repository[I IDsConstraint, T StructsConstraint[I]] interface {
+	Find(ctx context.Context, filters []Filter, opts *QueryOptions) ([]*T, error)
+	FindOne(ctx context.Context, filters []Filter) (*T, error)
+	FindByID(ctx context.Context, id string) (*T, error)
+	Create(ctx context.Context, entity T) (*T, error)
+	CreateMany(ctx context.Context, entities []T, opts *BatchOptions) ([]*T, error)
+	Update(ctx context.Context, id string, entity T) (*T, error)
+	Upsert(ctx context.Context, entity T, conflictColumns []string) (*T, error)
+	Patch(ctx context.Context, id string, fields map[string]any) (*T, error)
+	MergePatch(ctx context.Context, id string, patch []byte) (*T, error)
+	Delete(ctx context.Context, id string) error
+	DeleteMany(ctx context.Context, filters []Filter) (int64, error)
+	Count(ctx context.Context, filters []Filter, opts *QueryOptions) (int64, error)
+	Exists(ctx context.Context, filters []Filter, opts *QueryOptions) (bool, error)
+	ExistsWithID(ctx context.Context, id string) (bool, error)
+	FindWithPagination(ctx context.Context, filters []Filter, page, pageSize int, orderBy string) ([]*T, int64, error)
+	FindPage(ctx context.Context, filters []Filter, q CursorQuery) (*Page[T], error)
+	Stream(ctx context.Context, filters []Filter, opts *QueryOptions) iter.Seq2[*T, error]
+}
+
+var (
+	_ Repository[string, structs.Server] = (*GenericRepository[string, structs.Server])(nil)
+	_ Repository[string, structs.Server] = (*MemoryRepository[string, structs.Server])(nil)
+	_ AuditTrail                         = (*GenericRepository[string, structs.Server])(nil)
+)
+
 type GenericRepository[I IDsConstraint, T StructsConstraint[I]] struct {
-	db        *sqlx.DB
+	db *sqlx.DB
+	// set by WithTx, takes precedence over a transaction carried by the context
+	tx        *sqlx.Tx
 	tableName string
+	// db tags of T, the only fields filters may reference
+	columns map[string]int
+	// columns of T stored as JSON
+	jsonColumns map[string]bool
+	// set when T has a deleted_at column
+	softDelete bool
+	// set when T has a version column
+	versioned bool
+	dialect   Dialect
+	options   options
+}
+
+type options struct {
+	// HMAC key of pagination cursors
+	cursorSecret []byte
+	// detected from the driver when nil
+	dialect Dialect
+	hooks   []Hook
+	// RedactArgs when nil
+	redact Redactor
+	audit  bool
+}
+
+type Option func(*options)
+
+// WithCursorSecret sets the key cursors are signed with. Without it a random
+// key is generated, so cursors do not survive restarts or other replicas.
+func WithCursorSecret(secret []byte) Option {
+	return func(o *options) {
+		o.cursorSecret = secret
+	}
 }
 
 type QueryOptions struct {
+	// comma separated sort keys in the ParseSort format, applied before Sort
 	OrderBy string
+	Sort    []SortKey
 	Limit   int
 	Offset  int
+	// soft-deleted rows are excluded unless requested
+	Deleted DeletedScope
+	// selected columns, all of them when empty
+	Columns []string
 }
 
 type Filter struct {
 	Field string
-	// =, !=, >, <, >=, <=, LIKE, IN, NOT IN, OR
+	// =, !=, <>, >, <, >=, <=, LIKE, NOT LIKE, ILIKE, NOT ILIKE,
+	// IN, NOT IN, IS NULL, IS NOT NULL, EXPR and RAW for NewUnsafeRawFilter,
+	// @>, ?, ?|, ?& on JSON columns
 	Operator string
 	Value    any
 }
 
-func NewRepository[I IDsConstraint, T StructsConstraint[I]](db *sqlx.DB, tableName string) *GenericRepository[I, T] {
+func NewRepository[I IDsConstraint, T StructsConstraint[I]](db *sqlx.DB, tableName string, opts ...Option) *GenericRepository[I, T] {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if len(o.cursorSecret) == 0 {
+		o.cursorSecret = make([]byte, 32)
+		// a zero secret would let anyone forge cursors
+		if _, err := rand.Read(o.cursorSecret); err != nil {
+			panic(fmt.Sprintf("repo: failed to generate a cursor secret: %v", err))
+		}
+	}
+
+	dialect := o.dialect
+	if dialect == nil {
+		dialect = Postgres
+		if db != nil {
+			dialect = dialectFor(db.DriverName())
+		}
+	}
+
+	entityType := reflect.TypeOf((*T)(nil)).Elem()
+	columns := entityColumns(entityType)
+	_, softDelete := columns[deletedAtColumn]
+	_, versioned := columns[versionColumn]
+
 	return &GenericRepository[I, T]{
-		db:        db,
-		tableName: tableName,
+		db:          db,
+		tableName:   tableName,
+		columns:     columns,
+		jsonColumns: entityJSONColumns(entityType, columns),
+		softDelete:  softDelete,
+		versioned:   versioned,
+		dialect:     dialect,
+		options:     o,
 	}
 }
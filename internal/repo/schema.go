@@ -0,0 +1,204 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrSchemaDrift = errors.New("schema drift")
+
+type DriftKind string
+
+const (
+	DriftMissingTable  DriftKind = "missing table"
+	DriftMissingColumn DriftKind = "missing column"
+	// the table has a column the struct lacks, SELECT * cannot scan it
+	DriftExtraColumn DriftKind = "extra column"
+	DriftType        DriftKind = "type mismatch"
+	// the column accepts NULL but the field cannot hold it
+	DriftNullable DriftKind = "nullable column"
+)
+
+type DriftIssue struct {
+	Table  string
+	Column string
+	Kind   DriftKind
+	Detail string
+}
+
+func (i DriftIssue) String() string {
+	s := fmt.Sprintf("%s: %s", i.Table, i.Kind)
+	if i.Column != "" {
+		s += " " + i.Column
+	}
+	if i.Detail != "" {
+		s += " (" + i.Detail + ")"
+	}
+	return s
+}
+
+type SchemaDriftError struct {
+	Issues []DriftIssue
+}
+
+func (e *SchemaDriftError) Error() string {
+	issues := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		issues[i] = issue.String()
+	}
+	return fmt.Sprintf("schema drift: %s", strings.Join(issues, "; "))
+}
+
+func (e *SchemaDriftError) Unwrap() error {
+	return ErrSchemaDrift
+}
+
+// SchemaChecker compares an entity with the table it is stored in.
+type SchemaChecker interface {
+	CheckSchema(ctx context.Context) ([]DriftIssue, error)
+}
+
+// CheckSchemas collects the drift of every checker.
+func CheckSchemas(ctx context.Context, checkers ...SchemaChecker) ([]DriftIssue, error) {
+	var issues []DriftIssue
+	for _, checker := range checkers {
+		found, err := checker.CheckSchema(ctx)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, found...)
+	}
+	return issues, nil
+}
+
+// VerifySchemas fails with a *SchemaDriftError when any table drifted, to
+// be called on startup before serving traffic.
+func VerifySchemas(ctx context.Context, checkers ...SchemaChecker) error {
+	issues, err := CheckSchemas(ctx, checkers...)
+	if err != nil {
+		return err
+	}
+	if len(issues) > 0 {
+		return &SchemaDriftError{Issues: issues}
+	}
+	return nil
+}
+
+// tableColumn is a column as the database describes it.
+type tableColumn struct {
+	Name     string `db:"name"`
+	Type     string `db:"type"`
+	Nullable bool   `db:"nullable"`
+}
+
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// CheckSchema compares the db tags of T with the columns of the table.
+func (r *GenericRepository[I, T]) CheckSchema(ctx context.Context) ([]DriftIssue, error) {
+	columns, err := r.dialect.tableColumns(ctx, r.conn(ctx, "CheckSchema"), r.tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe table %s: %w", r.tableName, err)
+	}
+	if len(columns) == 0 {
+		return []DriftIssue{{Table: r.tableName, Kind: DriftMissingTable}}, nil
+	}
+
+	var issues []DriftIssue
+	entityType := reflect.TypeOf((*T)(nil)).Elem()
+	described := make(map[string]tableColumn, len(columns))
+	for _, column := range columns {
+		described[column.Name] = column
+		if !r.hasColumn(column.Name) {
+			issues = append(issues, DriftIssue{Table: r.tableName, Column: column.Name, Kind: DriftExtraColumn, Detail: column.Type})
+		}
+	}
+
+	for _, name := range r.columnNames() {
+		column, ok := described[name]
+		if !ok {
+			issues = append(issues, DriftIssue{Table: r.tableName, Column: name, Kind: DriftMissingColumn})
+			continue
+		}
+
+		fieldType := entityType.Field(r.columns[name]).Type
+		if accepted := r.dialect.columnTypes(fieldType); accepted != nil && !slices.Contains(accepted, strings.ToLower(column.Type)) {
+			issues = append(issues, DriftIssue{
+				Table:  r.tableName,
+				Column: name,
+				Kind:   DriftType,
+				Detail: fmt.Sprintf("%s cannot hold %s", fieldType, column.Type),
+			})
+		}
+
+		if column.Nullable && fieldType.Kind() != reflect.Ptr && !reflect.PointerTo(fieldType).Implements(scannerType) {
+			issues = append(issues, DriftIssue{Table: r.tableName, Column: name, Kind: DriftNullable, Detail: fieldType.String()})
+		}
+	}
+
+	return issues, nil
+}
+
+func (postgresDialect) tableColumns(ctx context.Context, q querier, table string) ([]tableColumn, error) {
+	var columns []tableColumn
+	err := q.SelectContext(ctx, &columns, `
+		SELECT column_name AS name, data_type AS type, is_nullable = 'YES' AS nullable
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1
+		ORDER BY ordinal_position`, table)
+	return columns, err
+}
+
+// columnTypes lists the data_type names of information_schema a field of
+// type t can be stored in.
+func (postgresDialect) columnTypes(t reflect.Type) []string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		return []string{"timestamp with time zone", "timestamp without time zone", "date"}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return []string{"bytea"}
+	case isJSONType(t):
+		return []string{"jsonb", "json"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return []string{"boolean"}
+	case reflect.String:
+		return []string{"text", "character varying", "character", "uuid"}
+	case reflect.Int8, reflect.Int16, reflect.Uint8:
+		return []string{"smallint", "integer", "bigint"}
+	case reflect.Int, reflect.Int32, reflect.Int64, reflect.Uint16, reflect.Uint32:
+		return []string{"integer", "bigint"}
+	case reflect.Uint, reflect.Uint64:
+		return []string{"bigint", "numeric"}
+	case reflect.Float32, reflect.Float64:
+		return []string{"real", "double precision", "numeric"}
+	default:
+		return nil
+	}
+}
+
+func (sqliteDialect) tableColumns(ctx context.Context, q querier, table string) ([]tableColumn, error) {
+	var columns []tableColumn
+	// primary keys count as NOT NULL, SQLite only enforces it for INTEGER ones
+	err := q.SelectContext(ctx, &columns, `
+		SELECT name, type, "notnull" = 0 AND pk = 0 AS nullable
+		FROM pragma_table_info(?1)
+		ORDER BY cid`, table)
+	return columns, err
+}
+
+// columnTypes accepts any declared type, SQLite does not enforce them.
+func (sqliteDialect) columnTypes(t reflect.Type) []string {
+	return nil
+}
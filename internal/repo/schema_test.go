@@ -0,0 +1,61 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/xligenda/ods-servers/internal/structs"
+)
+
+func TestCheckSchema(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	// servers as an older build left it: invite_channel was never added,
+	// a dropped field kept its column and version is nullable
+	db.MustExec(`
+CREATE TABLE servers_drifted (
+    id         INTEGER PRIMARY KEY,
+    guild      INTEGER NOT NULL,
+    roles      TEXT NOT NULL DEFAULT '{}',
+    legacy     TEXT,
+    version    INTEGER,
+    deleted_at DATETIME
+);`)
+
+	servers := NewRepository[string, structs.Server](db, "servers")
+	drifted := NewRepository[string, structs.Server](db, "servers_drifted")
+	missing := NewRepository[string, structs.Server](db, "servers_missing")
+
+	issues, err := servers.CheckSchema(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 0 {
+		t.Errorf("servers drifted: %v", issues)
+	}
+
+	issues, err = CheckSchemas(ctx, servers, drifted, missing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DriftIssue{
+		{Table: "servers_drifted", Column: "legacy", Kind: DriftExtraColumn, Detail: "TEXT"},
+		{Table: "servers_drifted", Column: "invite_channel", Kind: DriftMissingColumn},
+		{Table: "servers_drifted", Column: "version", Kind: DriftNullable, Detail: "int64"},
+		{Table: "servers_missing", Kind: DriftMissingTable},
+	}
+	if !reflect.DeepEqual(issues, want) {
+		t.Errorf("issues = %v, want %v", issues, want)
+	}
+
+	if err := VerifySchemas(ctx, servers); err != nil {
+		t.Errorf("VerifySchemas(servers) = %v", err)
+	}
+	err = VerifySchemas(ctx, servers, drifted)
+	var drift *SchemaDriftError
+	if !errors.As(err, &drift) || !errors.Is(err, ErrSchemaDrift) || len(drift.Issues) != 3 {
+		t.Errorf("VerifySchemas(servers, drifted) = %v, want the 3 issues of servers_drifted", err)
+	}
+}
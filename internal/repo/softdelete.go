@@ -0,0 +1,125 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// deletedAtColumn enables soft delete for entities with a field tagged db:"deleted_at".
+const deletedAtColumn = "deleted_at"
+
+// DeletedScope selects soft-deleted rows, it has no effect without a deleted_at column.
+type DeletedScope int
+
+const (
+	ExcludeDeleted DeletedScope = iota
+	IncludeDeleted
+	OnlyDeleted
+)
+
+func (opts *QueryOptions) WithDeleted() *QueryOptions {
+	opts.Deleted = IncludeDeleted
+	return opts
+}
+
+func (opts *QueryOptions) OnlyDeleted() *QueryOptions {
+	opts.Deleted = OnlyDeleted
+	return opts
+}
+
+func scopeOf(opts *QueryOptions) DeletedScope {
+	if opts == nil {
+		return ExcludeDeleted
+	}
+	return opts.Deleted
+}
+
+// scopeCondition returns the condition selecting the rows of the scope, empty for all rows.
+func (r *GenericRepository[I, T]) scopeCondition(scope DeletedScope) string {
+	if !r.softDelete {
+		return ""
+	}
+
+	switch scope {
+	case ExcludeDeleted:
+		return r.dialect.quote(deletedAtColumn) + " IS NULL"
+	case OnlyDeleted:
+		return r.dialect.quote(deletedAtColumn) + " IS NOT NULL"
+	default:
+		return ""
+	}
+}
+
+// activeClause restricts single-row writes to rows that are not soft-deleted.
+func (r *GenericRepository[I, T]) activeClause() string {
+	if condition := r.scopeCondition(ExcludeDeleted); condition != "" {
+		return " AND " + condition
+	}
+	return ""
+}
+
+// Restore clears the deletion mark of a soft-deleted entity.
+func (r *GenericRepository[I, T]) Restore(ctx context.Context, id string) (*T, error) {
+	if !r.softDelete {
+		return nil, fmt.Errorf("%s has no %s column", r.tableName, deletedAtColumn)
+	}
+
+	scope := idScope(id)
+	scope.deleted = OnlyDeleted
+	return r.auditedOne(ctx, "Restore", scope, func(ctx context.Context) (*T, error) {
+		return r.restore(ctx, id)
+	})
+}
+
+func (r *GenericRepository[I, T]) restore(ctx context.Context, id string) (*T, error) {
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s = NULL WHERE id = %s AND %s%s",
+		r.dialect.quote(r.tableName),
+		r.dialect.quote(deletedAtColumn),
+		r.dialect.placeholder(1),
+		r.scopeCondition(OnlyDeleted),
+		r.dialect.returning(),
+	)
+
+	var restoredEntity T
+	err := r.conn(ctx, "Restore").GetContext(ctx, &restoredEntity, query, id)
+	if err != nil {
+		return nil, wrapError(fmt.Sprintf("failed to restore entity with id %s", id), err)
+	}
+
+	return &restoredEntity, nil
+}
+
+// Purge permanently removes the rows soft-deleted before the given time,
+// it is meant for retention jobs.
+func (r *GenericRepository[I, T]) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	if !r.softDelete {
+		return 0, fmt.Errorf("%s has no %s column", r.tableName, deletedAtColumn)
+	}
+
+	var purged int64
+	scope := &auditScope{filters: []Filter{{Field: deletedAtColumn, Operator: "<", Value: deletedBefore}}, deleted: OnlyDeleted}
+	err := r.audited(ctx, "Purge", scope, func(ctx context.Context) ([]*T, error) {
+		var err error
+		purged, err = r.purge(ctx, deletedBefore)
+		return nil, err
+	})
+	return purged, err
+}
+
+func (r *GenericRepository[I, T]) purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE %s < %s",
+		r.dialect.quote(r.tableName),
+		r.dialect.quote(deletedAtColumn),
+		r.dialect.placeholder(1),
+	)
+
+	result, err := r.conn(ctx, "Purge").ExecContext(ctx, query, r.dialect.bindValue(deletedBefore))
+	if err != nil {
+		return 0, wrapError("failed to purge entities", err)
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,127 @@
+package repo
+
+import (
+	"fmt"
+	"strings"
+)
+
+type NullsOrder string
+
+const (
+	NullsDefault NullsOrder = ""
+	NullsFirst   NullsOrder = "NULLS FIRST"
+	NullsLast    NullsOrder = "NULLS LAST"
+)
+
+type SortKey struct {
+	Field string
+	Desc  bool
+	Nulls NullsOrder
+}
+
+func Asc(field string) SortKey {
+	return SortKey{Field: field}
+}
+
+func Desc(field string) SortKey {
+	return SortKey{Field: field, Desc: true}
+}
+
+// ParseSort parses a comma separated list of sort keys. A key is either
+// "-field" for descending order, as in "?sort=-online,name", or the SQL
+// form "field [ASC|DESC] [NULLS FIRST|NULLS LAST]". A signed field must not
+// also name a direction, "-name ASC" is rejected.
+func ParseSort(s string) ([]SortKey, error) {
+	var keys []SortKey
+	for _, part := range strings.Split(s, ",") {
+		words := strings.Fields(part)
+		if len(words) == 0 {
+			continue
+		}
+
+		key := SortKey{Field: words[0]}
+		signed := true
+		switch key.Field[0] {
+		case '-':
+			key.Field, key.Desc = key.Field[1:], true
+		case '+':
+			key.Field = key.Field[1:]
+		default:
+			signed = false
+		}
+		if key.Field == "" {
+			return nil, fmt.Errorf("empty sort field in %q", part)
+		}
+
+		// the direction is a whole word, "DESCNULLS" or "ASCENDING" are not
+		modifiers := words[1:]
+		if len(modifiers) > 0 {
+			switch direction := strings.ToUpper(modifiers[0]); direction {
+			case "ASC", "DESC":
+				if signed {
+					return nil, fmt.Errorf("sort field %s has both a sign and %s", words[0], direction)
+				}
+				key.Desc = direction == "DESC"
+				modifiers = modifiers[1:]
+			}
+		}
+
+		nulls := NullsOrder(strings.ToUpper(strings.Join(modifiers, " ")))
+
+		switch nulls {
+		case NullsDefault, NullsFirst, NullsLast:
+			key.Nulls = nulls
+		default:
+			return nil, fmt.Errorf("invalid sort modifiers %q for %s", strings.Join(modifiers, " "), key.Field)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func (r *GenericRepository[I, T]) buildOrderClause(keys []SortKey) (string, error) {
+	if len(keys) == 0 {
+		return "", nil
+	}
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		if !r.hasColumn(key.Field) {
+			return "", &InvalidFilterError{Field: key.Field, Reason: "unknown sort field"}
+		}
+
+		part := r.dialect.quote(key.Field)
+		if key.Desc {
+			part += " DESC"
+		} else {
+			part += " ASC"
+		}
+
+		switch key.Nulls {
+		case NullsDefault:
+		case NullsFirst, NullsLast:
+			part += " " + string(key.Nulls)
+		default:
+			return "", &InvalidFilterError{Field: key.Field, Reason: "invalid nulls order"}
+		}
+
+		parts[i] = part
+	}
+
+	return " ORDER BY " + strings.Join(parts, ", "), nil
+}
+
+// sortKeys merges the legacy OrderBy string with the structured sort keys.
+func (opts *QueryOptions) sortKeys() ([]SortKey, error) {
+	if opts.OrderBy == "" {
+		return opts.Sort, nil
+	}
+
+	keys, err := ParseSort(opts.OrderBy)
+	if err != nil {
+		return nil, &InvalidFilterError{Field: opts.OrderBy, Reason: err.Error()}
+	}
+	return append(keys, opts.Sort...), nil
+}
@@ -0,0 +1,62 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []SortKey
+		wantErr bool
+	}{
+		{in: "", want: nil},
+		{in: "name", want: []SortKey{Asc("name")}},
+		{in: "-online,+name", want: []SortKey{Desc("online"), Asc("name")}},
+		{in: " name desc , tag ASC ", want: []SortKey{Desc("name"), Asc("tag")}},
+		{in: "name DESC NULLS LAST", want: []SortKey{{Field: "name", Desc: true, Nulls: NullsLast}}},
+		{in: "-name nulls first", want: []SortKey{{Field: "name", Desc: true, Nulls: NullsFirst}}},
+		{in: "name,,tag", want: []SortKey{Asc("name"), Asc("tag")}},
+		{in: "-name ASC", wantErr: true},
+		{in: "+name DESC", wantErr: true},
+		{in: "-", wantErr: true},
+		{in: "name sideways", wantErr: true},
+		{in: "name DESC NULLS", wantErr: true},
+		{in: "name DESCNULLS FIRST", wantErr: true},
+		{in: "name ASCNULLS LAST", wantErr: true},
+		{in: "name ASCENDING", wantErr: true},
+		{in: "name DESC DESC", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSort(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSort(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseSort(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFindOptions(t *testing.T) {
+	for name, r := range filterRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			found, err := r.Find(context.Background(), nil, NewQueryOptions().WithOrderBy("server DESC").WithSort(Desc("target")).WithLimit(2).WithOffset(1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := joinCodes(found); got != "c b" {
+				t.Errorf("found %q, want %q", got, "c b")
+			}
+
+			if _, err := r.Find(context.Background(), nil, NewQueryOptions().WithSort(Asc("missing"))); !errors.Is(err, ErrInvalidFilter) {
+				t.Errorf("unknown sort field: err = %v, want ErrInvalidFilter", err)
+			}
+		})
+	}
+}
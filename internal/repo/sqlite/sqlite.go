@@ -0,0 +1,58 @@
+// Package sqlite opens SQLite databases for the repositories. It is kept apart
+// from package repo so only the tests and tools that use SQLite link the driver.
+package sqlite
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/jmoiron/sqlx"
+	_ "modernc.org/sqlite"
+)
+
+// AuditLogSchema creates the audit_log table repo.WithAudit writes to, the
+// SQLite counterpart of the Postgres migration 0005_create_audit_log.
+const AuditLogSchema = `
+CREATE TABLE IF NOT EXISTS audit_log (
+    id         INTEGER PRIMARY KEY,
+    table_name TEXT NOT NULL,
+    entity_id  TEXT NOT NULL,
+    operation  TEXT NOT NULL CHECK (operation IN ('create', 'update', 'delete')),
+    -- NULL for writes made outside of a request
+    actor      TEXT,
+    changes    TEXT NOT NULL,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_entity_idx ON audit_log (table_name, entity_id, id);`
+
+// Open opens a SQLite database through the pure-Go driver, for local
+// development and self-contained integration tests. ":memory:" keeps the
+// database in memory for as long as the returned DB is open.
+func Open(path string) (*sqlx.DB, error) {
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	// LIKE is case-insensitive by default, the dialect implements ILIKE itself
+	params.Add("_pragma", "case_sensitive_like(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	// the repository binds times in its own SQLite format, the driver writes
+	// others in a format it parses back as well
+	params.Set("_time_format", "sqlite")
+
+	db, err := sqlx.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+
+	// every connection to :memory: would open a database of its own
+	if path == ":memory:" {
+		db.SetMaxOpenConns(1)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to sqlite database: %w", err)
+	}
+
+	return db, nil
+}
@@ -0,0 +1,113 @@
+package repo
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/xligenda/ods-servers/internal/repo/sqlite"
+	"github.com/xligenda/ods-servers/internal/structs"
+)
+
+// testSchema mirrors the migrations in SQLite's dialect.
+const testSchema = `
+CREATE TABLE servers (
+    id             INTEGER PRIMARY KEY,
+    guild          INTEGER NOT NULL,
+    invite_channel INTEGER NOT NULL DEFAULT 0,
+    roles          TEXT NOT NULL DEFAULT '{}',
+    version        INTEGER NOT NULL DEFAULT 0,
+    deleted_at     DATETIME
+);
+CREATE TABLE users (
+    id      INTEGER PRIMARY KEY,
+    servers TEXT NOT NULL DEFAULT '{}'
+);
+CREATE TABLE invites (
+    id           TEXT PRIMARY KEY,
+    server       INTEGER NOT NULL REFERENCES servers (id),
+    requested_by INTEGER NOT NULL,
+    target       INTEGER NOT NULL,
+    expires_at   DATETIME NOT NULL,
+    created_at   DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now') || '000000+00:00'),
+    used_at      DATETIME,
+    revoked_at   DATETIME
+);`
+
+// openTestDB opens an in-memory SQLite database with the schema of the entities.
+func openTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	return openTestDBAt(t, ":memory:")
+}
+
+// openTestDBFile opens a database file instead, unlike :memory: it allows
+// concurrent connections.
+func openTestDBFile(t *testing.T) *sqlx.DB {
+	t.Helper()
+	return openTestDBAt(t, filepath.Join(t.TempDir(), "test.db"))
+}
+
+func openTestDBAt(t *testing.T, path string) *sqlx.DB {
+	t.Helper()
+
+	db, err := sqlite.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	db.MustExec(testSchema)
+	db.MustExec(sqlite.AuditLogSchema)
+	return db
+}
+
+func TestPurgeComparesInstants(t *testing.T) {
+	db := openTestDB(t)
+	r := NewRepository[string, structs.Server](db, "servers")
+	if _, err := r.Create(context.Background(), structs.Server{Tag: 1, Roles: structs.ServerRoles{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Delete(context.Background(), "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a local time just after the deletion is earlier as text, but not as an instant
+	deletedBefore := time.Now().Add(time.Second).In(time.FixedZone("UTC-5", -5*3600))
+	purged, err := r.Purge(context.Background(), deletedBefore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 1 {
+		t.Errorf("purged = %d, want 1", purged)
+	}
+
+}
+
+func TestSQLiteTimeFormat(t *testing.T) {
+	db := openTestDB(t)
+	db.MustExec("INSERT INTO servers (id, guild) VALUES (1, 10)")
+	r := NewRepository[string, structs.Invite](db, "invites")
+
+	expires := time.Date(2026, 1, 1, 12, 0, 0, 0, time.FixedZone("UTC+3", 3*3600))
+	if _, err := r.Create(context.Background(), structs.Invite{Code: "a", Server: 1, ExpiresAt: expires}); err != nil {
+		t.Fatal(err)
+	}
+
+	var stored string
+	if err := db.Get(&stored, "SELECT CAST(expires_at AS TEXT) FROM invites"); err != nil {
+		t.Fatal(err)
+	}
+	if stored != "2026-01-01 09:00:00.000000000+00:00" {
+		t.Errorf("stored = %q", stored)
+	}
+
+	invite, err := r.FindByID(context.Background(), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !invite.ExpiresAt.Equal(expires) {
+		t.Errorf("expires_at = %v, want %v", invite.ExpiresAt, expires)
+	}
+}
@@ -0,0 +1,56 @@
+package repo
+
+import (
+	"context"
+	"iter"
+)
+
+// Stream iterates the matching rows one at a time instead of loading them
+// all like Find. The rows are closed when the loop ends, breaks or ctx is
+// cancelled; iteration stops after the first error.
+//
+//	for user, err := range users.Stream(ctx, nil, nil) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// Inside a transaction no other query may run on it until the loop ends.
+func (r *GenericRepository[I, T]) Stream(ctx context.Context, filters []Filter, opts *QueryOptions) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		query, args, err := r.buildSelectQuery(filters, opts)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+
+		rows, err := r.conn(ctx, "Stream").QueryxContext(ctx, query, args...)
+		if err != nil {
+			yield(nil, wrapError("failed to execute query", err))
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			// database/sql closes the rows on cancellation asynchronously
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+
+			var entity T
+			if err := rows.StructScan(&entity); err != nil {
+				yield(nil, wrapError("failed to scan row", err))
+				return
+			}
+			if !yield(&entity, nil) {
+				return
+			}
+		}
+
+		if err := rows.Err(); err != nil {
+			yield(nil, wrapError("failed to iterate rows", err))
+		}
+	}
+}
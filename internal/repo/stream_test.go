@@ -0,0 +1,86 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/xligenda/ods-servers/internal/structs"
+)
+
+func TestStream(t *testing.T) {
+	db := openTestDB(t)
+	repositories := map[string]Repository[string, structs.Server]{
+		"sql":    NewRepository[string, structs.Server](db, "servers"),
+		"memory": NewMemoryRepository[string, structs.Server]("servers"),
+	}
+
+	for name, r := range repositories {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for tag := 1; tag <= 3; tag++ {
+				if _, err := r.Create(ctx, structs.Server{Tag: tag, Roles: structs.ServerRoles{}}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var tags []int
+			for server, err := range r.Stream(ctx, nil, &QueryOptions{OrderBy: "id DESC"}) {
+				if err != nil {
+					t.Fatal(err)
+				}
+				tags = append(tags, server.Tag)
+			}
+			if len(tags) != 3 || tags[0] != 3 || tags[2] != 1 {
+				t.Errorf("tags = %v, want [3 2 1]", tags)
+			}
+
+			t.Run("break", func(t *testing.T) {
+				for range r.Stream(ctx, nil, nil) {
+					break
+				}
+				// the single connection of :memory: is back in the pool
+				if name == "sql" && db.Stats().InUse != 0 {
+					t.Errorf("%d connections in use after break, want 0", db.Stats().InUse)
+				}
+				if count, err := r.Count(ctx, nil, nil); err != nil || count != 3 {
+					t.Errorf("Count() = %d, %v after break, want 3", count, err)
+				}
+			})
+
+			t.Run("invalid filter", func(t *testing.T) {
+				yields := 0
+				for server, err := range r.Stream(ctx, []Filter{{Field: "missing", Operator: "=", Value: 1}}, nil) {
+					yields++
+					if server != nil || !errors.Is(err, ErrInvalidFilter) {
+						t.Errorf("yield = %v, %v, want nil, %v", server, err, ErrInvalidFilter)
+					}
+				}
+				if yields != 1 {
+					t.Errorf("yields = %d, want 1", yields)
+				}
+			})
+
+			t.Run("cancelled", func(t *testing.T) {
+				ctx, cancel := context.WithCancel(ctx)
+				defer cancel()
+
+				var rows int
+				var last error
+				for server, err := range r.Stream(ctx, nil, nil) {
+					if err != nil {
+						last = err
+						continue
+					}
+					rows++
+					if server.Tag == 1 {
+						cancel()
+					}
+				}
+				if rows != 1 || !errors.Is(last, context.Canceled) {
+					t.Errorf("rows = %d, err = %v, want 1 row and %v", rows, last, context.Canceled)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const defaultTxRetries = 3
+
+// querier is implemented by both *sqlx.DB and *sqlx.Tx, so repository
+// methods run the same way inside and outside of a transaction.
+type querier interface {
+	sqlx.ExtContext
+	GetContext(ctx context.Context, dest any, query string, args ...any) error
+	SelectContext(ctx context.Context, dest any, query string, args ...any) error
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type TxOptions struct {
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+	// attempts after a serialization failure (SQLSTATE 40001, SQLITE_BUSY), 3 by default, negative disables retries
+	MaxRetries int
+}
+
+type txKey struct{}
+
+type txState struct {
+	tx *sqlx.Tx
+	// savepoints opened so far, used to name the next one
+	savepoints int
+}
+
+// TxFromContext returns the transaction carried by ctx, if any.
+func TxFromContext(ctx context.Context) (*sqlx.Tx, bool) {
+	state, ok := ctx.Value(txKey{}).(*txState)
+	if !ok {
+		return nil, false
+	}
+	return state.tx, true
+}
+
+// WithTx returns a copy of the repository bound to the transaction.
+func (r *GenericRepository[I, T]) WithTx(tx *sqlx.Tx) *GenericRepository[I, T] {
+	bound := *r
+	bound.tx = tx
+	return &bound
+}
+
+// conn returns the transaction or database to run the operation on,
+// reporting its statements to the hooks.
+func (r *GenericRepository[I, T]) conn(ctx context.Context, operation string) querier {
+	if r.tx != nil {
+		return r.hooked(r.tx, operation, true)
+	}
+	if tx, ok := TxFromContext(ctx); ok {
+		return r.hooked(tx, operation, true)
+	}
+	return r.hooked(r.db, operation, false)
+}
+
+// Transaction runs fn in a transaction carried by the context passed to it,
+// every repository method called with that context joins the transaction.
+// Nested calls run in savepoints, whose statements are reported to the hooks
+// as the Transaction operation.
+func (r *GenericRepository[I, T]) Transaction(ctx context.Context, opts *TxOptions, fn func(ctx context.Context) error) error {
+	if r.tx != nil {
+		ctx = context.WithValue(ctx, txKey{}, &txState{tx: r.tx})
+	}
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return runInSavepoint(ctx, state, r.conn(ctx, "Transaction"), fn)
+	}
+	return RunInTransaction(ctx, r.db, opts, fn)
+}
+
+// TransactionTx runs fn with the raw transaction, the form Transaction had
+// before the transaction was carried by the context.
+//
+// Deprecated: use Transaction and call the repository methods with its context.
+func (r *GenericRepository[I, T]) TransactionTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	return r.Transaction(ctx, nil, func(ctx context.Context) error {
+		tx, _ := TxFromContext(ctx)
+		return fn(tx)
+	})
+}
+
+// RunInTransaction runs fn in a new transaction, or in a savepoint when ctx
+// already carries one. Serialization failures of the outermost transaction
+// are retried with the whole fn, so fn must not have side effects outside
+// the database.
+func RunInTransaction(ctx context.Context, db *sqlx.DB, opts *TxOptions, fn func(ctx context.Context) error) error {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return runInSavepoint(ctx, state, state.tx, fn)
+	}
+
+	if opts == nil {
+		opts = &TxOptions{}
+	}
+	retries := opts.MaxRetries
+	if retries == 0 {
+		retries = defaultTxRetries
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := runInTx(ctx, db, opts, fn)
+		if err == nil || !isSerializationFailure(err) || attempt >= retries {
+			return err
+		}
+
+		backoff := time.Duration(attempt+1)*10*time.Millisecond + rand.N(10*time.Millisecond)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+	}
+}
+
+func runInTx(ctx context.Context, db *sqlx.DB, opts *TxOptions, fn func(ctx context.Context) error) (err error) {
+	tx, err := db.BeginTxx(ctx, &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, txKey{}, &txState{tx: tx})); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return fmt.Errorf("transaction error: %w, rollback error: %w", err, rollbackErr)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// runInSavepoint runs the savepoint statements on exec, the transaction of
+// state or a hooked wrapper of it.
+func runInSavepoint(ctx context.Context, state *txState, exec sqlx.ExecerContext, fn func(ctx context.Context) error) error {
+	state.savepoints++
+	// a plain identifier in every dialect, so it needs no quoting
+	name := fmt.Sprintf("sp_%d", state.savepoints)
+
+	if _, err := exec.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("failed to create savepoint: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			exec.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+			panic(p)
+		}
+	}()
+
+	if err := fn(ctx); err != nil {
+		if _, rollbackErr := exec.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rollbackErr != nil {
+			return fmt.Errorf("transaction error: %w, rollback error: %w", err, rollbackErr)
+		}
+		return err
+	}
+
+	if _, err := exec.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("failed to release savepoint: %w", err)
+	}
+
+	return nil
+}
+
+// isSerializationFailure also reports SQLITE_BUSY, SQLite fails a
+// transaction that cannot take its write lock instead of waiting for it.
+func isSerializationFailure(err error) bool {
+	var sqliteErr sqliteError
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code()&0xff == 5
+	}
+
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "40001"
+}
@@ -0,0 +1,150 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/xligenda/ods-servers/internal/structs"
+)
+
+func TestSavepoints(t *testing.T) {
+	ctx := context.Background()
+	var statements []string
+	hook := HookFunc(func(ctx context.Context, event QueryEvent) {
+		if event.Operation == "Transaction" && event.InTx {
+			statements = append(statements, event.Query)
+		}
+	})
+	r := NewRepository[string, structs.Server](openTestDB(t), "servers", WithHooks(hook))
+	failed := errors.New("failed")
+
+	err := r.Transaction(ctx, nil, func(ctx context.Context) error {
+		if _, err := r.Create(ctx, structs.Server{Tag: 1, Roles: structs.ServerRoles{}}); err != nil {
+			return err
+		}
+
+		// the failed nested transaction only rolls back its own writes
+		err := r.Transaction(ctx, nil, func(ctx context.Context) error {
+			if _, err := r.Create(ctx, structs.Server{Tag: 2, Roles: structs.ServerRoles{}}); err != nil {
+				return err
+			}
+			return failed
+		})
+		if !errors.Is(err, failed) {
+			t.Errorf("nested err = %v, want %v", err, failed)
+		}
+
+		return r.Transaction(ctx, nil, func(ctx context.Context) error {
+			_, err := r.Create(ctx, structs.Server{Tag: 3, Roles: structs.ServerRoles{}})
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"SAVEPOINT sp_1", "ROLLBACK TO SAVEPOINT sp_1", "SAVEPOINT sp_2", "RELEASE SAVEPOINT sp_2"}
+	if !slices.Equal(statements, want) {
+		t.Errorf("hooked statements = %q, want %q", statements, want)
+	}
+
+	for tag, want := range map[string]bool{"1": true, "2": false, "3": true} {
+		exists, err := r.ExistsWithID(ctx, tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exists != want {
+			t.Errorf("server %s exists = %v, want %v", tag, exists, want)
+		}
+	}
+
+	err = r.Transaction(ctx, nil, func(ctx context.Context) error {
+		if _, err := r.Create(ctx, structs.Server{Tag: 4, Roles: structs.ServerRoles{}}); err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Errorf("err = %v, want %v", err, failed)
+	}
+	if exists, _ := r.ExistsWithID(ctx, "4"); exists {
+		t.Error("the write of a failed transaction was committed")
+	}
+}
+
+func TestTransactionRetries(t *testing.T) {
+	serialization := &pq.Error{Code: "40001"}
+	other := errors.New("failed")
+
+	tests := []struct {
+		name     string
+		opts     *TxOptions
+		failures int
+		err      error
+		attempts int
+	}{
+		{name: "succeeds after retries", failures: 2, attempts: 3},
+		{name: "gives up after the default retries", failures: 10, err: serialization, attempts: 1 + defaultTxRetries},
+		{name: "custom retries", opts: &TxOptions{MaxRetries: 1}, failures: 10, err: serialization, attempts: 2},
+		{name: "retries disabled", opts: &TxOptions{MaxRetries: -1}, failures: 10, err: serialization, attempts: 1},
+		{name: "other errors are not retried", failures: -1, err: other, attempts: 1},
+	}
+
+	db := openTestDB(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := RunInTransaction(context.Background(), db, tt.opts, func(ctx context.Context) error {
+				attempts++
+				if tt.failures < 0 {
+					return other
+				}
+				if attempts <= tt.failures {
+					return serialization
+				}
+				return nil
+			})
+
+			if !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+			if attempts != tt.attempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.attempts)
+			}
+		})
+	}
+
+	t.Run("savepoints are not retried", func(t *testing.T) {
+		attempts := 0
+		err := RunInTransaction(context.Background(), db, nil, func(ctx context.Context) error {
+			return RunInTransaction(ctx, db, nil, func(ctx context.Context) error {
+				attempts++
+				return serialization
+			})
+		})
+		// the outer transaction retries the whole function instead
+		if !errors.Is(err, serialization) || attempts != 1+defaultTxRetries {
+			t.Errorf("err = %v, attempts = %d", err, attempts)
+		}
+	})
+}
+
+func TestTransactionTx(t *testing.T) {
+	ctx := context.Background()
+	r := NewRepository[string, structs.Server](openTestDB(t), "servers")
+
+	err := r.TransactionTx(ctx, func(tx *sqlx.Tx) error {
+		_, err := r.WithTx(tx).Create(ctx, structs.Server{Tag: 1, Roles: structs.ServerRoles{}})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists, _ := r.ExistsWithID(ctx, "1"); !exists {
+		t.Error("the write of the transaction was not committed")
+	}
+}
@@ -0,0 +1,76 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// versionColumn enables optimistic locking for entities with a field tagged db:"version".
+const versionColumn = "version"
+
+var ErrVersionConflict = errors.New("version conflict")
+
+// VersionConflictError is returned when the stored entity was modified since
+// the version the caller read.
+type VersionConflictError struct {
+	ID       string
+	Expected int64
+	// UpsertMany: the conflict keys of every entity that was not written,
+	// ID and Expected are those of the first one
+	Keys []string
+}
+
+func (e *VersionConflictError) Error() string {
+	if len(e.Keys) > 1 {
+		return fmt.Sprintf("%d entities were modified, conflicting keys %s", len(e.Keys), strings.Join(e.Keys, ", "))
+	}
+	return fmt.Sprintf("entity with id %s was modified, expected version %d", e.ID, e.Expected)
+}
+
+func (e *VersionConflictError) Unwrap() error {
+	return ErrVersionConflict
+}
+
+// versionIncrement is the SET expression bumping the version, qualified for upserts.
+func (r *GenericRepository[I, T]) versionIncrement(qualified bool) string {
+	column := r.dialect.quote(versionColumn)
+	if qualified {
+		return fmt.Sprintf("%s = %s.%s + 1", column, r.dialect.quote(r.tableName), column)
+	}
+	return fmt.Sprintf("%s = %s + 1", column, column)
+}
+
+func (r *GenericRepository[I, T]) entityVersion(entity T) int64 {
+	version, _ := toInt64(reflect.ValueOf(entity).Field(r.columns[versionColumn]).Interface())
+	return version
+}
+
+// missingRowError tells a version conflict from a missing row after a
+// versioned write matched nothing.
+func (r *GenericRepository[I, T]) missingRowError(ctx context.Context, id string, expected int64) error {
+	exists, err := r.ExistsWithID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return &VersionConflictError{ID: id, Expected: expected}
+	}
+	return fmt.Errorf("entity with id %s: %w", id, ErrNotFound)
+}
+
+func toInt64(value any) (int64, bool) {
+	v := reflect.ValueOf(value)
+	switch {
+	case v.CanInt():
+		return v.Int(), true
+	case v.CanUint():
+		return int64(v.Uint()), true
+	case v.CanFloat():
+		return int64(v.Float()), true
+	default:
+		return 0, false
+	}
+}
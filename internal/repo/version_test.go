@@ -0,0 +1,63 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/xligenda/ods-servers/internal/structs"
+)
+
+func TestVersionConflict(t *testing.T) {
+	repositories := map[string]Repository[string, structs.Server]{
+		"sql":    NewRepository[string, structs.Server](openTestDB(t), "servers"),
+		"memory": NewMemoryRepository[string, structs.Server]("servers"),
+	}
+
+	for name, r := range repositories {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			created := createServer(t, r, structs.ServerRoles{})
+			if created.Version != 0 {
+				t.Fatalf("created version = %d, want 0", created.Version)
+			}
+
+			first := *created
+			first.Guild = 11
+			updated, err := r.Update(ctx, "1", first)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if updated.Version != 1 || updated.Guild != 11 {
+				t.Errorf("updated = version %d guild %d, want version 1 guild 11", updated.Version, updated.Guild)
+			}
+
+			// a writer still holding version 0 must not overwrite the change
+			stale := *created
+			stale.Guild = 12
+			_, err = r.Update(ctx, "1", stale)
+			var conflict *VersionConflictError
+			if !errors.As(err, &conflict) || conflict.ID != "1" || conflict.Expected != 0 {
+				t.Fatalf("stale update: err = %v, want a version conflict expecting 0", err)
+			}
+
+			if _, err := r.Upsert(ctx, stale, nil); !errors.Is(err, ErrVersionConflict) {
+				t.Errorf("stale upsert: err = %v, want ErrVersionConflict", err)
+			}
+
+			missing := stale
+			missing.Tag = 2
+			if _, err := r.Update(ctx, "2", missing); !errors.Is(err, ErrNotFound) {
+				t.Errorf("missing row: err = %v, want ErrNotFound", err)
+			}
+
+			current, err := r.FindByID(ctx, "1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if current.Guild != 11 || current.Version != 1 {
+				t.Errorf("stored = version %d guild %d, want version 1 guild 11", current.Version, current.Guild)
+			}
+		})
+	}
+}
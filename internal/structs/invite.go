@@ -0,0 +1,35 @@
+package structs
+
+import "time"
+
+// Invite is a single-use guild invite issued to a specific user.
+type Invite struct {
+	Code        string     `db:"id" json:"code"`
+	Server      ServerTag  `db:"server" json:"server"`
+	RequestedBy DiscordID  `db:"requested_by" json:"requested_by"`
+	Target      DiscordID  `db:"target" json:"target"`
+	ExpiresAt   time.Time  `db:"expires_at" json:"expires_at"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+	UsedAt      *time.Time `db:"used_at" json:"used_at,omitempty"`
+	RevokedAt   *time.Time `db:"revoked_at" json:"revoked_at,omitempty"`
+}
+
+func (i Invite) GetID() string {
+	return i.Code
+}
+
+// InviteSnapshot is the use count of a guild invite at a point in time.
+type InviteSnapshot struct {
+	ID      string    `db:"id" json:"id"`
+	Code    string    `db:"code" json:"code"`
+	Server  ServerTag `db:"server" json:"server"`
+	Channel DiscordID `db:"channel" json:"channel"`
+	Inviter DiscordID `db:"inviter" json:"inviter"`
+	Uses    int       `db:"uses" json:"uses"`
+	MaxUses int       `db:"max_uses" json:"max_uses"`
+	TakenAt time.Time `db:"taken_at" json:"taken_at"`
+}
+
+func (s InviteSnapshot) GetID() string {
+	return s.ID
+}
@@ -1,10 +1,48 @@
 package structs
 
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
 type Server struct {
-	Tag   ServerTag
-	Roles map[DiscordID]RoleName
+	Tag   ServerTag `db:"id" json:"tag"`
+	Guild DiscordID `db:"guild" json:"guild"`
+	// channel staff invites are created in
+	InviteChannel DiscordID   `db:"invite_channel" json:"invite_channel"`
+	Roles         ServerRoles `db:"roles" json:"roles"`
+	// incremented on every write, concurrent edits of a stale copy are rejected
+	Version int64 `db:"version" json:"version"`
+	// set when the server was removed, its history is kept
+	DeletedAt *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
+}
+
+func (s Server) GetID() string {
+	return strconv.Itoa(s.Tag)
 }
 
 type ServerTag = int
-type DiscordID = int
+type DiscordID = Snowflake
 type RoleName = string
+
+// ServerRoles maps guild role IDs to staff role names, stored as JSON.
+type ServerRoles map[DiscordID]RoleName
+
+func (r *ServerRoles) Scan(src any) error {
+	return scanJSON(src, r)
+}
+
+func scanJSON(src any, dst any) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, dst)
+	case string:
+		return json.Unmarshal([]byte(v), dst)
+	default:
+		return fmt.Errorf("cannot scan %T into %T", src, dst)
+	}
+}
@@ -0,0 +1,94 @@
+package structs
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// discordEpoch is the first second of 2015 in milliseconds, the origin of snowflake timestamps.
+const discordEpoch = 1420070400000
+
+// Snowflake is a 64-bit Discord ID. It is encoded as a JSON string
+// the way the Discord API sends it and stored as BIGINT.
+type Snowflake uint64
+
+func ParseSnowflake(s string) (Snowflake, error) {
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid snowflake %q: %w", s, err)
+	}
+	return Snowflake(id), nil
+}
+
+func (s Snowflake) String() string {
+	return strconv.FormatUint(uint64(s), 10)
+}
+
+// CreatedAt returns the creation time embedded in the snowflake.
+func (s Snowflake) CreatedAt() time.Time {
+	return time.UnixMilli(int64(s>>22) + discordEpoch).UTC()
+}
+
+func (s Snowflake) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(s.String())), nil
+}
+
+// UnmarshalJSON accepts both string and numeric IDs, null leaves the zero value.
+func (s *Snowflake) UnmarshalJSON(data []byte) error {
+	str := string(data)
+	if str == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(str); err == nil {
+		str = unquoted
+	}
+	if str == "" {
+		*s = 0
+		return nil
+	}
+
+	id, err := ParseSnowflake(str)
+	if err != nil {
+		return err
+	}
+	*s = id
+	return nil
+}
+
+// MarshalText lets snowflakes be used as JSON object keys.
+func (s Snowflake) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Snowflake) UnmarshalText(text []byte) error {
+	id, err := ParseSnowflake(string(text))
+	if err != nil {
+		return err
+	}
+	*s = id
+	return nil
+}
+
+func (s *Snowflake) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*s = 0
+		return nil
+	case int64:
+		*s = Snowflake(v)
+		return nil
+	case []byte:
+		return s.UnmarshalText(v)
+	case string:
+		return s.UnmarshalText([]byte(v))
+	default:
+		return fmt.Errorf("cannot scan %T into Snowflake", src)
+	}
+}
+
+// Value stores the ID as a signed BIGINT, snowflakes never use the sign bit.
+func (s Snowflake) Value() (driver.Value, error) {
+	return int64(s), nil
+}
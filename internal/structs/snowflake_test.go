@@ -0,0 +1,125 @@
+package structs
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSnowflakeJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Snowflake
+		wantErr bool
+	}{
+		{in: `"175928847299117063"`, want: 175928847299117063},
+		{in: `175928847299117063`, want: 175928847299117063},
+		{in: `""`, want: 0},
+		{in: `null`, want: 0},
+		{in: `"18446744073709551615"`, want: 18446744073709551615},
+		{in: `"-1"`, wantErr: true},
+		{in: `"12a"`, wantErr: true},
+		{in: `1.5`, wantErr: true},
+		{in: `"18446744073709551616"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		var got Snowflake
+		err := json.Unmarshal([]byte(tt.in), &got)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Unmarshal(%s) = %d, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Unmarshal(%s) = %d, %v, want %d", tt.in, got, err, tt.want)
+			continue
+		}
+
+		// IDs are always sent back as strings
+		data, err := json.Marshal(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `"` + tt.want.String() + `"`; string(data) != want {
+			t.Errorf("Marshal(%d) = %s, want %s", got, data, want)
+		}
+	}
+}
+
+func TestSnowflakeMapKeys(t *testing.T) {
+	roles := ServerRoles{175928847299117063: "admin"}
+	data, err := json.Marshal(roles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"175928847299117063":"admin"}`; string(data) != want {
+		t.Errorf("Marshal = %s, want %s", data, want)
+	}
+
+	var decoded map[Snowflake]string
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded[175928847299117063] != "admin" {
+		t.Errorf("Unmarshal = %v", decoded)
+	}
+}
+
+func TestSnowflakeScan(t *testing.T) {
+	tests := []struct {
+		src     any
+		want    Snowflake
+		wantErr bool
+	}{
+		{src: nil, want: 0},
+		{src: int64(175928847299117063), want: 175928847299117063},
+		{src: []byte("175928847299117063"), want: 175928847299117063},
+		{src: "175928847299117063", want: 175928847299117063},
+		{src: "abc", wantErr: true},
+		{src: 1.5, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got := Snowflake(1)
+		err := got.Scan(tt.src)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Scan(%#v) = %d, want an error", tt.src, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Scan(%#v) = %d, %v, want %d", tt.src, got, err, tt.want)
+		}
+	}
+}
+
+func TestSnowflakeValue(t *testing.T) {
+	value, err := Snowflake(175928847299117063).Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != int64(175928847299117063) {
+		t.Errorf("Value() = %#v, want int64(175928847299117063)", value)
+	}
+
+	var scanned Snowflake
+	if err := scanned.Scan(value); err != nil || scanned != 175928847299117063 {
+		t.Errorf("Scan(Value()) = %d, %v", scanned, err)
+	}
+}
+
+func TestSnowflakeCreatedAt(t *testing.T) {
+	// the example of the Discord API reference
+	got := Snowflake(175928847299117063).CreatedAt()
+	want := time.Date(2016, 4, 30, 11, 18, 25, 796*int(time.Millisecond), time.UTC)
+	if !got.Equal(want) || got.Location() != time.UTC {
+		t.Errorf("CreatedAt() = %v, want %v", got, want)
+	}
+
+	if got := Snowflake(0).CreatedAt(); !got.Equal(time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("CreatedAt() of 0 = %v, want the Discord epoch", got)
+	}
+}
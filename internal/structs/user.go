@@ -1,7 +1,18 @@
 package structs
 
 type User struct {
-	ID DiscordID
+	ID DiscordID `db:"id" json:"id"`
 	// guilds where this user has a membership
-	Servers map[ServerTag][]RoleName
+	Servers UserServers `db:"servers" json:"servers"`
+}
+
+func (u User) GetID() string {
+	return u.ID.String()
+}
+
+// UserServers maps server tags to the roles held there, stored as JSON.
+type UserServers map[ServerTag][]RoleName
+
+func (s *UserServers) Scan(src any) error {
+	return scanJSON(src, s)
 }